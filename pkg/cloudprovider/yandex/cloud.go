@@ -1,15 +1,20 @@
 package yandex
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"log"
-	"os"
-	"strings"
+	"sync"
+	"text/template"
 	"time"
 
-	v1 "k8s.io/client-go/listers/core/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
 
@@ -19,8 +24,6 @@ import (
 
 	"k8s.io/client-go/tools/cache"
 
-	"github.com/yandex-cloud/go-sdk/iamkey"
-
 	"github.com/pkg/errors"
 	ycsdk "github.com/yandex-cloud/go-sdk"
 	cloudprovider "k8s.io/cloud-provider"
@@ -32,11 +35,46 @@ const (
 	envClusterName        = "YANDEX_CLUSTER_NAME"
 	envRouteTableID       = "YANDEX_CLOUD_ROUTE_TABLE_ID"
 	envServiceAccountJSON = "YANDEX_CLOUD_SERVICE_ACCOUNT_JSON"
+	envServiceAccountFile = "YANDEX_CLOUD_SERVICE_ACCOUNT_JSON_FILE"
 	envFolderID           = "YANDEX_CLOUD_FOLDER_ID"
+	envInstanceFolderIDs  = "YANDEX_CLOUD_INSTANCE_FOLDER_IDS"
+	envInstanceCloudID    = "YANDEX_CLOUD_INSTANCE_CLOUD_ID"
 	envLbListenerSubnetID = "YANDEX_CLOUD_DEFAULT_LB_LISTENER_SUBNET_ID"
 	envLbTgNetworkID      = "YANDEX_CLOUD_DEFAULT_LB_TARGET_GROUP_NETWORK_ID"
 	envInternalNetworkIDs = "YANDEX_CLOUD_INTERNAL_NETWORK_IDS"
 	envExternalNetworkIDs = "YANDEX_CLOUD_EXTERNAL_NETWORK_IDS"
+	envZone               = "YANDEX_CLOUD_ZONE"
+	envRegion             = "YANDEX_CLOUD_REGION"
+	envInstanceTypeFormat = "YANDEX_CLOUD_INSTANCE_TYPE_FORMAT"
+	envPrimaryIPFamily    = "YANDEX_CLOUD_PRIMARY_IP_FAMILY"
+
+	envReserveStaticAddresses = "YANDEX_CLOUD_RESERVE_STATIC_ADDRESSES"
+	envDisruptionGuard        = "YANDEX_CLOUD_LB_DISRUPTION_GUARD"
+
+	envReportHostnameAddress    = "YANDEX_CLOUD_REPORT_HOSTNAME_ADDRESS"
+	envReportInternalDNSAddress = "YANDEX_CLOUD_REPORT_INTERNAL_DNS_ADDRESS"
+
+	envNodeNameMapping = "YANDEX_CLOUD_NODE_NAME_MAPPING"
+	envNodeNameLabel   = "YANDEX_CLOUD_NODE_NAME_LABEL"
+
+	envUserStopMarker               = "YANDEX_CLOUD_USER_STOP_MARKER"
+	envReportPreemptedAsNonExistent = "YANDEX_CLOUD_REPORT_PREEMPTED_AS_NONEXISTENT"
+	envPreemptionPollInterval       = "YANDEX_CLOUD_PREEMPTION_POLL_INTERVAL"
+
+	envNodeInstanceLabels         = "YANDEX_CLOUD_NODE_INSTANCE_LABELS"
+	envNodeInstanceLabelPrefix    = "YANDEX_CLOUD_NODE_INSTANCE_LABEL_PREFIX"
+	envNodeAttributesSyncInterval = "YANDEX_CLOUD_NODE_ATTRIBUTES_SYNC_INTERVAL"
+
+	envInstanceCacheRefreshInterval = "YANDEX_CLOUD_INSTANCE_CACHE_REFRESH_INTERVAL"
+
+	defaultInstanceCacheRefreshInterval = time.Minute
+
+	envCredentialsType                  = "YANDEX_CLOUD_CREDENTIALS_TYPE"
+	envIAMTokenFile                     = "YANDEX_CLOUD_IAM_TOKEN_FILE"
+	envOAuthToken                       = "YANDEX_CLOUD_OAUTH_TOKEN"
+	envWorkloadIdentityServiceAccountID = "YANDEX_CLOUD_WORKLOAD_IDENTITY_SERVICE_ACCOUNT_ID"
+	envWorkloadIdentityTokenFile        = "YANDEX_CLOUD_WORKLOAD_IDENTITY_TOKEN_FILE"
+	envWorkloadIdentityEndpoint         = "YANDEX_CLOUD_WORKLOAD_IDENTITY_ENDPOINT"
 )
 
 // CloudConfig includes all the necessary configuration for creating Cloud object
@@ -50,8 +88,54 @@ type CloudConfig struct {
 	LocalZone          string
 	RouteTableID       string
 
-	InternalNetworkIDsSet map[string]struct{}
-	ExternalNetworkIDsSet map[string]struct{}
+	// ReserveStaticAddresses makes external load balancers get reserved addresses by default
+	ReserveStaticAddresses bool
+	// DisruptionGuard refuses disruptive load balancer changes, nil disables it
+	DisruptionGuard *disruptionGuard
+
+	// LoadBalancerClasses are the profiles of the load balancer classes handled by the CCM
+	LoadBalancerClasses map[string]*loadBalancerClass
+
+	// InstanceFolderIDs are the additional folders searched for instances
+	InstanceFolderIDs []string
+	// InstanceCloudID makes all folders of the cloud be searched for instances
+	InstanceCloudID string
+
+	// AddressRules select the types of instance addresses, the first matching rule wins
+	AddressRules []addressRule
+
+	// PrimaryIPFamily addresses are reported first in Node addresses
+	PrimaryIPFamily v1.IPFamily
+
+	// ReportHostnameAddress and ReportInternalDNSAddress add Hostname and InternalDNS Node addresses from the instance FQDN
+	ReportHostnameAddress    bool
+	ReportInternalDNSAddress bool
+
+	// NodeNameMapping is the strategy used to find instances by Node names
+	NodeNameMapping nodeNameMapping
+	// NodeNameInstanceLabel is the instance label holding the Node name, used by the InstanceLabel strategy
+	NodeNameInstanceLabel string
+
+	// UserStopMarker is the instance label or metadata key marking preemptible instances stopped by a user
+	UserStopMarker string
+	// ReportPreemptedAsNonExistent makes the Nodes of preempted instances get deleted instead of being shut down
+	ReportPreemptedAsNonExistent bool
+	// PreemptionPollInterval is how often preemptible instances are checked for preemption, zero disables the checks
+	PreemptionPollInterval time.Duration
+
+	// NodeInstanceLabels is the allowlist of instance labels copied to Nodes
+	NodeInstanceLabels []string
+	// NodeInstanceLabelPrefix is prepended to the keys of instance labels copied to Nodes
+	NodeInstanceLabelPrefix string
+	// NodeTaints are applied to the Nodes matching their selectors
+	NodeTaints []nodeTaint
+	// NodeAttributesSyncInterval is how often Node labels and taints are synchronized, zero disables the synchronization
+	NodeAttributesSyncInterval time.Duration
+
+	instanceTypeTemplate *template.Template
+
+	// InstanceCacheRefreshInterval is how often the instance cache is refreshed, zero disables the cache
+	InstanceCacheRefreshInterval time.Duration
 
 	Credentials ycsdk.Credentials
 }
@@ -62,14 +146,23 @@ type Cloud struct {
 	nodeTargetGroupSyncer *NodeTargetGroupSyncer
 	config                CloudConfig
 
-	nodeLister v1.NodeLister
+	// client is set once the CCM is initialized
+	client     kubernetes.Interface
+	nodeLister listersv1.NodeLister
+	recorder   record.EventRecorder
+
+	// userStops caches the metadata marker of stopped preemptible instances by instance ID,
+	// list results carry no metadata and it doesn't change while the instance is down
+	userStops sync.Map
 }
 
 func init() {
+	registerMetrics()
+
 	cloudprovider.RegisterCloudProvider(
 		providerName,
-		func(_ io.Reader) (cloudprovider.Interface, error) {
-			config, err := NewCloudConfig()
+		func(configReader io.Reader) (cloudprovider.Interface, error) {
+			config, err := NewCloudConfig(configReader)
 			if err != nil {
 				return nil, err
 			}
@@ -79,37 +172,46 @@ func init() {
 				return nil, err
 			}
 
+			if rc, ok := config.Credentials.(*reloadingCredentials); ok {
+				rc.SetIAMTokenService(api.IAMTokenSvc)
+			}
+
+			api.ComputeSvc.SetInstanceFolders(config.InstanceFolderIDs, config.InstanceCloudID)
+
+			err = verifyLocalRegion(context.Background(), config, api)
+			if err != nil {
+				return nil, err
+			}
+
 			return NewCloud(*config, api), nil
 		})
 }
 
-// NewCloudConfig creates a new instance of CloudConfig object
-func NewCloudConfig() (*CloudConfig, error) {
+// NewCloudConfig creates a new instance of CloudConfig object from the optional config file and environment variables.
+// Environment variables take precedence over values from the config file.
+func NewCloudConfig(configReader io.Reader) (*CloudConfig, error) {
 	cloudConfig := &CloudConfig{}
 	metadata := NewMetadataService()
 
-	// Retrieve Service Account Json
-	saJSON := os.Getenv(envServiceAccountJSON)
-	if saJSON == "" {
-		return nil, fmt.Errorf("environment variable %q is required", envServiceAccountJSON)
-	}
-	var iamKey iamkey.Key
-	err := json.Unmarshal([]byte(saJSON), &iamKey)
+	cfgFile, err := readCloudConfigFile(configReader)
 	if err != nil {
-		return nil, errors.Wrap(err, "malformed service account json")
+		return nil, err
 	}
-	credentials, err := ycsdk.ServiceAccountKey(&iamKey)
+	cfgFile.applyEnvOverrides()
+
+	// Retrieve Credentials
+	credentials, err := newCredentials(cfgFile)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid auth credentials")
+		return nil, err
 	}
 
 	cloudConfig.Credentials = credentials
 
 	// Retrieve FolderID
-	// firstly - try to find it in env. variables
-	folderID := os.Getenv(envFolderID)
+	// non-empty env. variables override the config, the instance metadata is the last resort
+	folderID := cfgFile.FolderID
 	if folderID == "" {
-		// if env. variable is missing - then fallback to MetadataService
+		// if it is missing - then fallback to MetadataService
 		var err error
 		folderID, err = metadata.GetFolderID()
 		if err != nil {
@@ -117,47 +219,131 @@ func NewCloudConfig() (*CloudConfig, error) {
 		}
 	}
 	cloudConfig.FolderID = folderID
+	cloudConfig.InstanceFolderIDs = cfgFile.InstanceFolderIDs
+	cloudConfig.InstanceCloudID = cfgFile.InstanceCloudID
 
-	cloudConfig.ClusterName = os.Getenv(envClusterName)
+	cloudConfig.ClusterName = cfgFile.ClusterName
 	if len(cloudConfig.ClusterName) == 0 {
-		log.Fatalf("%q env is required", envClusterName)
+		return nil, fmt.Errorf("cluster name is required: set %q or \"clusterName\" in the cloud config", envClusterName)
 	}
 
-	cloudConfig.RouteTableID = os.Getenv(envRouteTableID)
+	cloudConfig.RouteTableID = cfgFile.RouteTableID
 
-	cloudConfig.lbListenerSubnetID = os.Getenv(envLbListenerSubnetID)
+	cloudConfig.lbListenerSubnetID = cfgFile.LbListenerSubnetID
 
-	cloudConfig.lbTgNetworkID = os.Getenv(envLbTgNetworkID)
+	cloudConfig.lbTgNetworkID = cfgFile.LbTargetGroupNetworkID
 	if len(cloudConfig.lbTgNetworkID) == 0 {
-		log.Fatalf("%q env is required", envLbTgNetworkID)
+		return nil, fmt.Errorf("default LB target group network ID is required: set %q or \"lbTargetGroupNetworkID\" in the cloud config", envLbTgNetworkID)
+	}
+
+	cloudConfig.LoadBalancerClasses, err = parseLoadBalancerClasses(cfgFile.LoadBalancerClasses)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudConfig.DisruptionGuard, err = parseDisruptionGuard(cfgFile.DisruptionGuard)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudConfig.AddressRules, err = parseAddressRules(cfgFile.AddressRules, cfgFile.InternalNetworkIDs, cfgFile.ExternalNetworkIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v1.IPFamily(cfgFile.PrimaryIPFamily) {
+	case "", v1.IPv4Protocol:
+		cloudConfig.PrimaryIPFamily = v1.IPv4Protocol
+	case v1.IPv6Protocol:
+		cloudConfig.PrimaryIPFamily = v1.IPv6Protocol
+	default:
+		return nil, fmt.Errorf("unsupported primary IP family %q, expected %q or %q", cfgFile.PrimaryIPFamily, v1.IPv4Protocol, v1.IPv6Protocol)
 	}
 
-	cloudConfig.InternalNetworkIDsSet = make(map[string]struct{})
-	cloudConfig.ExternalNetworkIDsSet = make(map[string]struct{})
+	cloudConfig.ReportHostnameAddress = cfgFile.ReportHostnameAddress
+	cloudConfig.ReserveStaticAddresses = cfgFile.ReserveStaticAddresses
+	cloudConfig.ReportInternalDNSAddress = cfgFile.ReportInternalDNSAddress
+
+	cloudConfig.NodeNameMapping, err = parseNodeNameMapping(cfgFile.NodeNameMapping.Strategy, cfgFile.NodeNameMapping.InstanceLabel)
+	if err != nil {
+		return nil, err
+	}
+	cloudConfig.NodeNameInstanceLabel = cfgFile.NodeNameMapping.InstanceLabel
+
+	cloudConfig.UserStopMarker = cfgFile.Preemption.UserStopMarker
+	cloudConfig.ReportPreemptedAsNonExistent = cfgFile.Preemption.ReportAsNonExistent
+	if !cfgFile.Preemption.DrainDisabled {
+		cloudConfig.PreemptionPollInterval = cfgFile.Preemption.PollInterval.Duration
+		if cloudConfig.PreemptionPollInterval <= 0 {
+			cloudConfig.PreemptionPollInterval = defaultPreemptionPollInterval
+		}
+	}
 
-	if len(os.Getenv(envInternalNetworkIDs)) > 0 {
-		for _, networkID := range strings.Split(os.Getenv(envInternalNetworkIDs), ",") {
-			cloudConfig.InternalNetworkIDsSet[networkID] = struct{}{}
+	cloudConfig.NodeInstanceLabels = cfgFile.NodeAttributes.InstanceLabels
+	cloudConfig.NodeInstanceLabelPrefix = cfgFile.NodeAttributes.InstanceLabelPrefix
+	if len(cloudConfig.NodeInstanceLabelPrefix) == 0 {
+		cloudConfig.NodeInstanceLabelPrefix = defaultInstanceLabelPrefix
+	}
+	if err := validateNodeTaints(cfgFile.NodeAttributes.Taints); err != nil {
+		return nil, err
+	}
+	cloudConfig.NodeTaints = cfgFile.NodeAttributes.Taints
+	if !cfgFile.NodeAttributes.SyncDisabled {
+		cloudConfig.NodeAttributesSyncInterval = cfgFile.NodeAttributes.SyncInterval.Duration
+		if cloudConfig.NodeAttributesSyncInterval <= 0 {
+			cloudConfig.NodeAttributesSyncInterval = defaultNodeAttributesSyncInterval
 		}
 	}
 
-	if len(os.Getenv(envExternalNetworkIDs)) > 0 {
-		for _, networkID := range strings.Split(os.Getenv(envExternalNetworkIDs), ",") {
-			cloudConfig.ExternalNetworkIDsSet[networkID] = struct{}{}
+	cloudConfig.instanceTypeTemplate, err = parseInstanceTypeFormat(cfgFile.InstanceTypeFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfgFile.InstanceCache.Disabled {
+		cloudConfig.InstanceCacheRefreshInterval = cfgFile.InstanceCache.RefreshInterval.Duration
+		if cloudConfig.InstanceCacheRefreshInterval <= 0 {
+			cloudConfig.InstanceCacheRefreshInterval = defaultInstanceCacheRefreshInterval
 		}
 	}
 
 	// Retrieve LocalZone
-	localZone := "ru-central1-b"
+	// firstly - try to find it in the config, then fallback to MetadataService
+	localZone := cfgFile.Zone
+	if localZone == "" {
+		localZone, err = metadata.GetZone()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get zone from instance metadata, set %q or \"zone\" in the cloud config", envZone)
+		}
+	}
 	cloudConfig.LocalZone = localZone
-	cloudConfig.LocalRegion, err = GetRegion(localZone)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get region from zone: %s", localZone)
+	cloudConfig.LocalRegion = cfgFile.Region
+	if cloudConfig.LocalRegion == "" {
+		cloudConfig.LocalRegion, err = GetRegion(localZone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get region from zone: %s", localZone)
+		}
 	}
 
 	return cloudConfig, nil
 }
 
+// verifyLocalRegion checks that the configured region matches the one the local zone belongs to.
+func verifyLocalRegion(ctx context.Context, config *CloudConfig, api *yapi.YandexCloudAPI) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	region, err := api.ComputeSvc.GetZoneRegion(ctx, config.LocalZone)
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify zone %q", config.LocalZone)
+	}
+	if region != config.LocalRegion {
+		return fmt.Errorf("zone %q belongs to region %q, but region %q is configured", config.LocalZone, region, config.LocalRegion)
+	}
+
+	return nil
+}
+
 // NewCloud creates a new instance of Cloud object
 func NewCloud(config CloudConfig, api *yapi.YandexCloudAPI) *Cloud {
 	return &Cloud{
@@ -169,6 +355,7 @@ func NewCloud(config CloudConfig, api *yapi.YandexCloudAPI) *Cloud {
 // Initialize passes a Kubernetes clientBuilder interface to the cloud provider
 func (yc *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
 	clientset := clientBuilder.ClientOrDie("cloud-controller-manager")
+	yc.client = clientset
 
 	informerFactory := informers.NewSharedInformerFactory(clientset, time.Second*30)
 	serviceInformer := informerFactory.Core().V1().Services()
@@ -182,6 +369,51 @@ func (yc *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 
 	yc.nodeLister = nodeInformer.Lister()
 
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	yc.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "yandex-cloud-controller-manager"})
+
+	if rc, ok := yc.config.Credentials.(*reloadingCredentials); ok {
+		go rc.Run(stop)
+	}
+
+	if yc.config.NodeAttributesSyncInterval > 0 {
+		nodeAttributesSyncer := newNodeAttributesSyncer(yc, clientset, nodeInformer)
+		go func() {
+			if !cache.WaitForCacheSync(stop, nodeInformer.Informer().HasSynced) {
+				return
+			}
+			nodeAttributesSyncer.Run(stop, yc.config.NodeAttributesSyncInterval)
+		}()
+	}
+
+	if yc.config.PreemptionPollInterval > 0 {
+		preemptionController := &PreemptionController{
+			cloud:      yc,
+			client:     clientset,
+			nodeLister: yc.nodeLister,
+			source:     &computePreemptionSource{cloud: yc},
+		}
+		go func() {
+			if !cache.WaitForCacheSync(stop, nodeInformer.Informer().HasSynced) {
+				return
+			}
+			preemptionController.Run(stop, yc.config.PreemptionPollInterval)
+		}()
+	}
+
+	loadBalancerClassController := newLoadBalancerClassController(yc, clientset, serviceInformer, nodeInformer)
+	go func() {
+		if !cache.WaitForCacheSync(stop, serviceInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced) {
+			return
+		}
+		loadBalancerClassController.Run(stop)
+	}()
+
+	if yc.config.InstanceCacheRefreshInterval > 0 {
+		go yc.yandexService.ComputeSvc.RunInstanceCache(stop, yc.config.InstanceCacheRefreshInterval)
+	}
+
 	go serviceInformer.Informer().Run(stop)
 	go nodeInformer.Informer().Run(stop)
 
@@ -233,7 +465,6 @@ func (yc *Cloud) HasClusterID() bool {
 }
 
 // InstancesV2 returns a InstancesV2 interface if supported
-// TODO (zuzzas): implement once it's stable enough (starting in v0.20.0)
 func (yc *Cloud) InstancesV2() (cloudprovider.InstancesV2, bool) {
-	return nil, false
+	return yc, true
 }
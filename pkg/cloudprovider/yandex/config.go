@@ -0,0 +1,248 @@
+package yandex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	cloudConfigAPIVersion = "yandex.cloud-controller-manager.deckhouse.io/v1alpha1"
+	cloudConfigKind       = "CloudConfig"
+)
+
+// cloudConfigFile is the on-disk representation of the file passed via --cloud-config.
+// Both YAML and JSON are accepted. Every field can be overridden by the corresponding environment variable.
+type cloudConfigFile struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	ClusterName  string `json:"clusterName,omitempty"`
+	FolderID     string `json:"folderID,omitempty"`
+	RouteTableID string `json:"routeTableID,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	Region       string `json:"region,omitempty"`
+
+	// InstanceFolderIDs are the additional folders the cluster's instances may reside in.
+	InstanceFolderIDs []string `json:"instanceFolderIDs,omitempty"`
+	// InstanceCloudID makes instances be searched in all folders of the cloud.
+	InstanceCloudID string `json:"instanceCloudID,omitempty"`
+
+	// ServiceAccountJSON holds the service account key inline, ServiceAccountJSONFile points to a file with it.
+	ServiceAccountJSON     string `json:"serviceAccountJSON,omitempty"`
+	ServiceAccountJSONFile string `json:"serviceAccountJSONFile,omitempty"`
+
+	// Credentials selects an alternative way to authenticate, the service account key is used by default.
+	Credentials credentialsConfig `json:"credentials,omitempty"`
+
+	LbListenerSubnetID     string `json:"lbListenerSubnetID,omitempty"`
+	LbTargetGroupNetworkID string `json:"lbTargetGroupNetworkID,omitempty"`
+
+	// ReserveStaticAddresses makes external NetworkLoadBalancers get reserved addresses that survive their re-creation.
+	ReserveStaticAddresses bool `json:"reserveStaticAddresses,omitempty"`
+
+	// DisruptionGuard refuses load balancer changes that break the traffic of Services.
+	DisruptionGuard disruptionGuardConfig `json:"disruptionGuard,omitempty"`
+
+	// LoadBalancerClasses are the profiles of the Services with spec.loadBalancerClass set.
+	// Services with classes that are neither configured nor built-in are left to other controllers.
+	LoadBalancerClasses []loadBalancerClass `json:"loadBalancerClasses,omitempty"`
+
+	InternalNetworkIDs []string `json:"internalNetworkIDs,omitempty"`
+	ExternalNetworkIDs []string `json:"externalNetworkIDs,omitempty"`
+
+	// AddressRules select the types of instance addresses and take precedence over the network ID lists.
+	AddressRules []addressRule `json:"addressRules,omitempty"`
+
+	// PrimaryIPFamily is either IPv4 (default) or IPv6, its addresses are reported first in Node addresses.
+	PrimaryIPFamily string `json:"primaryIPFamily,omitempty"`
+
+	// ReportHostnameAddress and ReportInternalDNSAddress add Hostname and InternalDNS Node addresses from the instance FQDN.
+	ReportHostnameAddress    bool `json:"reportHostnameAddress,omitempty"`
+	ReportInternalDNSAddress bool `json:"reportInternalDNSAddress,omitempty"`
+
+	// NodeNameMapping selects how instances are found by Kubernetes Node names.
+	NodeNameMapping nodeNameMappingConfig `json:"nodeNameMapping,omitempty"`
+
+	// Preemption configures how stopped preemptible instances are handled.
+	Preemption preemptionConfig `json:"preemption,omitempty"`
+
+	// NodeAttributes configures the Node labels and taints derived from instances.
+	NodeAttributes nodeAttributesConfig `json:"nodeAttributes,omitempty"`
+
+	// InstanceTypeFormat is a text/template used to format the node.kubernetes.io/instance-type label.
+	InstanceTypeFormat string `json:"instanceTypeFormat,omitempty"`
+
+	// InstanceCache configures the cache of the folder's instances used for instance lookups.
+	InstanceCache instanceCacheConfig `json:"instanceCache,omitempty"`
+}
+
+type nodeNameMappingConfig struct {
+	// Strategy is one of VMName (default), Hostname, FQDN, InstanceLabel and ProviderID.
+	Strategy string `json:"strategy,omitempty"`
+	// InstanceLabel is the instance label holding the Node name, used by the InstanceLabel strategy.
+	InstanceLabel string `json:"instanceLabel,omitempty"`
+}
+
+type preemptionConfig struct {
+	// UserStopMarker is the instance label or metadata key that marks preemptible instances stopped by a user,
+	// defaults to "ccm-user-stop". Stopped preemptible instances without it are considered preempted.
+	UserStopMarker string `json:"userStopMarker,omitempty"`
+	// ReportAsNonExistent reports preempted instances as non-existent, so that their Nodes are deleted.
+	ReportAsNonExistent bool `json:"reportAsNonExistent,omitempty"`
+
+	// DrainDisabled disables tainting the Nodes of preempted instances and removing them from target groups.
+	DrainDisabled bool `json:"drainDisabled,omitempty"`
+	// PollInterval is how often preemptible instances are checked for preemption, defaults to 5s.
+	PollInterval metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+type nodeAttributesConfig struct {
+	// InstanceLabels is the allowlist of instance labels copied to Nodes.
+	InstanceLabels []string `json:"instanceLabels,omitempty"`
+	// InstanceLabelPrefix is prepended to the keys of the copied labels, defaults to "instance.yandex.cloud/".
+	InstanceLabelPrefix string `json:"instanceLabelPrefix,omitempty"`
+	// Taints are applied to the Nodes matching their selectors.
+	Taints []nodeTaint `json:"taints,omitempty"`
+
+	// SyncDisabled disables the periodic synchronization, labels are then only set when Nodes are initialized.
+	SyncDisabled bool `json:"syncDisabled,omitempty"`
+	// SyncInterval is how often labels and taints of all Nodes are synchronized, defaults to 5m.
+	SyncInterval metav1.Duration `json:"syncInterval,omitempty"`
+}
+
+type disruptionGuardConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// MaintenanceWindows are the periods disruptive changes are allowed in.
+	MaintenanceWindows []maintenanceWindowConfig `json:"maintenanceWindows,omitempty"`
+}
+
+type maintenanceWindowConfig struct {
+	// Days are the weekdays the window starts on, e.g. "Sat", the window starts every day by default.
+	Days []string `json:"days,omitempty"`
+	// Start is the UTC time of day the window starts at, e.g. "02:00".
+	Start    string          `json:"start"`
+	Duration metav1.Duration `json:"duration"`
+}
+
+type instanceCacheConfig struct {
+	Disabled        bool            `json:"disabled,omitempty"`
+	RefreshInterval metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// readCloudConfigFile parses the cloud config file. A nil reader yields an empty config.
+func readCloudConfigFile(configReader io.Reader) (*cloudConfigFile, error) {
+	cfg := &cloudConfigFile{}
+	if configReader == nil {
+		return cfg, nil
+	}
+
+	data, err := io.ReadAll(configReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cloud config")
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return cfg, nil
+	}
+
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, errors.Wrap(err, "malformed cloud config")
+	}
+
+	if cfg.APIVersion != cloudConfigAPIVersion {
+		return nil, fmt.Errorf("unsupported cloud config apiVersion %q, expected %q", cfg.APIVersion, cloudConfigAPIVersion)
+	}
+	if cfg.Kind != cloudConfigKind {
+		return nil, fmt.Errorf("unsupported cloud config kind %q, expected %q", cfg.Kind, cloudConfigKind)
+	}
+
+	return cfg, nil
+}
+
+// applyEnvOverrides overrides config file values with non-empty environment variables.
+func (cfg *cloudConfigFile) applyEnvOverrides() {
+	overrideFromEnv(&cfg.ClusterName, envClusterName)
+	overrideFromEnv(&cfg.FolderID, envFolderID)
+	overrideFromEnv(&cfg.InstanceCloudID, envInstanceCloudID)
+	overrideFromEnv(&cfg.RouteTableID, envRouteTableID)
+	overrideFromEnv(&cfg.Zone, envZone)
+	overrideFromEnv(&cfg.Region, envRegion)
+	overrideFromEnv(&cfg.ServiceAccountJSON, envServiceAccountJSON)
+	overrideFromEnv(&cfg.ServiceAccountJSONFile, envServiceAccountFile)
+	overrideFromEnv(&cfg.LbListenerSubnetID, envLbListenerSubnetID)
+	overrideFromEnv(&cfg.LbTargetGroupNetworkID, envLbTgNetworkID)
+	overrideBoolFromEnv(&cfg.ReserveStaticAddresses, envReserveStaticAddresses)
+	overrideBoolFromEnv(&cfg.DisruptionGuard.Enabled, envDisruptionGuard)
+	overrideFromEnv(&cfg.InstanceTypeFormat, envInstanceTypeFormat)
+	overrideFromEnv(&cfg.PrimaryIPFamily, envPrimaryIPFamily)
+	overrideBoolFromEnv(&cfg.ReportHostnameAddress, envReportHostnameAddress)
+	overrideBoolFromEnv(&cfg.ReportInternalDNSAddress, envReportInternalDNSAddress)
+	overrideFromEnv(&cfg.NodeNameMapping.Strategy, envNodeNameMapping)
+	overrideFromEnv(&cfg.NodeNameMapping.InstanceLabel, envNodeNameLabel)
+	overrideFromEnv(&cfg.Preemption.UserStopMarker, envUserStopMarker)
+	overrideBoolFromEnv(&cfg.Preemption.ReportAsNonExistent, envReportPreemptedAsNonExistent)
+	if value := os.Getenv(envInstanceCacheRefreshInterval); len(value) > 0 {
+		if interval, err := time.ParseDuration(value); err == nil {
+			cfg.InstanceCache.RefreshInterval.Duration = interval
+		} else {
+			klog.Errorf("ignoring malformed %s=%q: %s", envInstanceCacheRefreshInterval, value, err)
+		}
+	}
+	if value := os.Getenv(envPreemptionPollInterval); len(value) > 0 {
+		if interval, err := time.ParseDuration(value); err == nil {
+			cfg.Preemption.PollInterval.Duration = interval
+		} else {
+			klog.Errorf("ignoring malformed %s=%q: %s", envPreemptionPollInterval, value, err)
+		}
+	}
+	overrideFromEnv(&cfg.NodeAttributes.InstanceLabelPrefix, envNodeInstanceLabelPrefix)
+	if value := os.Getenv(envNodeAttributesSyncInterval); len(value) > 0 {
+		if interval, err := time.ParseDuration(value); err == nil {
+			cfg.NodeAttributes.SyncInterval.Duration = interval
+		} else {
+			klog.Errorf("ignoring malformed %s=%q: %s", envNodeAttributesSyncInterval, value, err)
+		}
+	}
+	cfg.Credentials.applyEnvOverrides()
+
+	if value := os.Getenv(envInternalNetworkIDs); len(value) > 0 {
+		cfg.InternalNetworkIDs = strings.Split(value, ",")
+	}
+	if value := os.Getenv(envExternalNetworkIDs); len(value) > 0 {
+		cfg.ExternalNetworkIDs = strings.Split(value, ",")
+	}
+	if value := os.Getenv(envInstanceFolderIDs); len(value) > 0 {
+		cfg.InstanceFolderIDs = strings.Split(value, ",")
+	}
+	if value := os.Getenv(envNodeInstanceLabels); len(value) > 0 {
+		cfg.NodeAttributes.InstanceLabels = strings.Split(value, ",")
+	}
+}
+
+func overrideFromEnv(field *string, envName string) {
+	if value := os.Getenv(envName); len(value) > 0 {
+		*field = value
+	}
+}
+
+func overrideBoolFromEnv(field *bool, envName string) {
+	value := os.Getenv(envName)
+	if len(value) == 0 {
+		return
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.Errorf("ignoring malformed %s=%q: %s", envName, value, err)
+		return
+	}
+	*field = parsed
+}
@@ -0,0 +1,73 @@
+package yandex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCloudConfigFile(t *testing.T) {
+	const yamlConfig = `
+apiVersion: yandex.cloud-controller-manager.deckhouse.io/v1alpha1
+kind: CloudConfig
+clusterName: test
+folderID: folder
+lbTargetGroupNetworkID: network
+internalNetworkIDs:
+- internal1
+- internal2
+zone: kz1-a
+`
+
+	cfg, err := readCloudConfigFile(strings.NewReader(yamlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClusterName != "test" || cfg.FolderID != "folder" || cfg.LbTargetGroupNetworkID != "network" || cfg.Zone != "kz1-a" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if len(cfg.InternalNetworkIDs) != 2 {
+		t.Errorf("expected 2 internal network IDs, got %v", cfg.InternalNetworkIDs)
+	}
+
+	const jsonConfig = `{"apiVersion": "yandex.cloud-controller-manager.deckhouse.io/v1alpha1", "kind": "CloudConfig", "clusterName": "json"}`
+	cfg, err = readCloudConfigFile(strings.NewReader(jsonConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClusterName != "json" {
+		t.Errorf("unexpected cluster name %q", cfg.ClusterName)
+	}
+
+	cfg, err = readCloudConfigFile(nil)
+	if err != nil || cfg == nil {
+		t.Errorf("nil reader should produce an empty config, got %+v, %v", cfg, err)
+	}
+
+	_, err = readCloudConfigFile(strings.NewReader("apiVersion: v1\nkind: CloudConfig\n"))
+	if err == nil {
+		t.Error("should return non-nil err on unsupported apiVersion")
+	}
+
+	_, err = readCloudConfigFile(strings.NewReader("apiVersion: yandex.cloud-controller-manager.deckhouse.io/v1alpha1\nkind: CloudConfig\nunknownField: 1\n"))
+	if err == nil {
+		t.Error("should return non-nil err on unknown fields")
+	}
+}
+
+func TestCloudConfigFileEnvOverrides(t *testing.T) {
+	t.Setenv(envClusterName, "from-env")
+	t.Setenv(envExternalNetworkIDs, "ext1,ext2")
+
+	cfg := &cloudConfigFile{ClusterName: "from-file", FolderID: "folder", ExternalNetworkIDs: []string{"file"}}
+	cfg.applyEnvOverrides()
+
+	if cfg.ClusterName != "from-env" {
+		t.Errorf("env should override cluster name, got %q", cfg.ClusterName)
+	}
+	if cfg.FolderID != "folder" {
+		t.Errorf("folder ID should be kept from the file, got %q", cfg.FolderID)
+	}
+	if len(cfg.ExternalNetworkIDs) != 2 {
+		t.Errorf("env should override external network IDs, got %v", cfg.ExternalNetworkIDs)
+	}
+}
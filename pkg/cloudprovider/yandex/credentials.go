@@ -0,0 +1,231 @@
+package yandex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	ycsdk "github.com/yandex-cloud/go-sdk"
+	"github.com/yandex-cloud/go-sdk/iamkey"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	credentialsTypeServiceAccountKey          = "ServiceAccountKey"
+	credentialsTypeInstanceServiceAccount     = "InstanceServiceAccount"
+	credentialsTypeIAMTokenFile               = "IAMTokenFile"
+	credentialsTypeOAuthToken                 = "OAuthToken"
+	credentialsTypeWorkloadIdentityFederation = "WorkloadIdentityFederation"
+
+	defaultWorkloadIdentityEndpoint = "https://auth.yandex.cloud/oauth/token"
+
+	// tokens read from files are re-read this often, so that the SDK picks up rotated tokens
+	iamTokenFileRefreshPeriod = time.Minute
+)
+
+// credentialsConfig selects the way the CCM authenticates in Yandex.Cloud.
+type credentialsConfig struct {
+	// Type is one of ServiceAccountKey (default), InstanceServiceAccount, IAMTokenFile, OAuthToken
+	// and WorkloadIdentityFederation.
+	Type string `json:"type,omitempty"`
+
+	// IAMTokenFile is a file with a static IAM token, used by the IAMTokenFile type.
+	IAMTokenFile string `json:"iamTokenFile,omitempty"`
+	// OAuthToken is a Yandex Passport OAuth token, used by the OAuthToken type.
+	OAuthToken string `json:"oauthToken,omitempty"`
+
+	// WorkloadIdentityFederation is used by the WorkloadIdentityFederation type.
+	WorkloadIdentityFederation workloadIdentityFederationConfig `json:"workloadIdentityFederation,omitempty"`
+}
+
+type workloadIdentityFederationConfig struct {
+	// ServiceAccountID is the ID of the service account the federated credentials are bound to.
+	ServiceAccountID string `json:"serviceAccountID,omitempty"`
+	// TokenFile is a projected Kubernetes service account token.
+	TokenFile string `json:"tokenFile,omitempty"`
+	// Endpoint is the token exchange endpoint, defaults to https://auth.yandex.cloud/oauth/token.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+func (cfg *credentialsConfig) applyEnvOverrides() {
+	overrideFromEnv(&cfg.Type, envCredentialsType)
+	overrideFromEnv(&cfg.IAMTokenFile, envIAMTokenFile)
+	overrideFromEnv(&cfg.OAuthToken, envOAuthToken)
+	overrideFromEnv(&cfg.WorkloadIdentityFederation.ServiceAccountID, envWorkloadIdentityServiceAccountID)
+	overrideFromEnv(&cfg.WorkloadIdentityFederation.TokenFile, envWorkloadIdentityTokenFile)
+	overrideFromEnv(&cfg.WorkloadIdentityFederation.Endpoint, envWorkloadIdentityEndpoint)
+}
+
+// newCredentials builds SDK credentials of the configured type.
+func newCredentials(cfgFile *cloudConfigFile) (ycsdk.Credentials, error) {
+	cfg := cfgFile.Credentials
+
+	switch cfg.Type {
+	case "", credentialsTypeServiceAccountKey:
+		if len(cfgFile.ServiceAccountJSON) > 0 {
+			return serviceAccountKeyCredentials(cfgFile.ServiceAccountJSON)
+		}
+		if len(cfgFile.ServiceAccountJSONFile) == 0 {
+			return nil, fmt.Errorf("service account json is required: set %q, %q or \"serviceAccountJSON\" in the cloud config", envServiceAccountJSON, envServiceAccountFile)
+		}
+
+		// keys stored in a file are watched and reloaded on rotation
+		content, err := os.ReadFile(cfgFile.ServiceAccountJSONFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read service account json from %q", cfgFile.ServiceAccountJSONFile)
+		}
+
+		return newReloadingCredentials(cfgFile.ServiceAccountJSONFile, content)
+
+	case credentialsTypeInstanceServiceAccount:
+		return ycsdk.InstanceServiceAccount(), nil
+
+	case credentialsTypeIAMTokenFile:
+		if cfg.IAMTokenFile == "" {
+			return nil, fmt.Errorf("IAM token file is required for %q credentials: set %q or \"credentials.iamTokenFile\" in the cloud config", cfg.Type, envIAMTokenFile)
+		}
+
+		return &iamTokenFileCredentials{path: cfg.IAMTokenFile}, nil
+
+	case credentialsTypeOAuthToken:
+		if cfg.OAuthToken == "" {
+			return nil, fmt.Errorf("OAuth token is required for %q credentials: set %q or \"credentials.oauthToken\" in the cloud config", cfg.Type, envOAuthToken)
+		}
+
+		return ycsdk.OAuthToken(cfg.OAuthToken), nil
+
+	case credentialsTypeWorkloadIdentityFederation:
+		wif := cfg.WorkloadIdentityFederation
+		if wif.ServiceAccountID == "" || wif.TokenFile == "" {
+			return nil, fmt.Errorf("service account ID and token file are required for %q credentials: set %q and %q", cfg.Type, envWorkloadIdentityServiceAccountID, envWorkloadIdentityTokenFile)
+		}
+		if wif.Endpoint == "" {
+			wif.Endpoint = defaultWorkloadIdentityEndpoint
+		}
+
+		return &workloadIdentityCredentials{
+			serviceAccountID: wif.ServiceAccountID,
+			tokenFile:        wif.TokenFile,
+			endpoint:         wif.Endpoint,
+			httpClient:       &http.Client{Timeout: 10 * time.Second},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown credentials type %q", cfg.Type)
+	}
+}
+
+func serviceAccountKeyCredentials(saJSON string) (ycsdk.Credentials, error) {
+	var iamKey iamkey.Key
+	err := json.Unmarshal([]byte(saJSON), &iamKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed service account json")
+	}
+	credentials, err := ycsdk.ServiceAccountKey(&iamKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid auth credentials")
+	}
+
+	return credentials, nil
+}
+
+// iamTokenFileCredentials reads an IAM token from a file that is updated by an external process.
+type iamTokenFileCredentials struct {
+	path string
+}
+
+func (c *iamTokenFileCredentials) YandexCloudAPICredentials() {}
+
+func (c *iamTokenFileCredentials) IAMToken(_ context.Context) (*iam.CreateIamTokenResponse, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read IAM token from %q", c.path)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("IAM token file %q is empty", c.path)
+	}
+
+	// The real expiration time is unknown, so make the SDK come back for the token soon.
+	return &iam.CreateIamTokenResponse{
+		IamToken:  token,
+		ExpiresAt: timestamppb.New(time.Now().Add(iamTokenFileRefreshPeriod)),
+	}, nil
+}
+
+// workloadIdentityCredentials exchanges a projected Kubernetes service account token
+// for an IAM token of a federated Yandex.Cloud service account.
+type workloadIdentityCredentials struct {
+	serviceAccountID string
+	tokenFile        string
+	endpoint         string
+	httpClient       *http.Client
+}
+
+func (c *workloadIdentityCredentials) YandexCloudAPICredentials() {}
+
+func (c *workloadIdentityCredentials) IAMToken(ctx context.Context) (*iam.CreateIamTokenResponse, error) {
+	subjectToken, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read service account token from %q", c.tokenFile)
+	}
+
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":             {c.serviceAccountID},
+		"subject_token":        {strings.TrimSpace(string(subjectToken))},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:id_token"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "token exchange request failed")
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code during token exchange: %d: %s", res.StatusCode, body)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, errors.Wrap(err, "malformed token exchange response")
+	}
+	if tokenResponse.AccessToken == "" {
+		return nil, errors.New("token exchange response does not contain an access token")
+	}
+
+	// refresh the token a bit earlier than it actually expires
+	expiresIn := time.Duration(tokenResponse.ExpiresIn)*time.Second - time.Minute
+	if expiresIn <= 0 {
+		expiresIn = iamTokenFileRefreshPeriod
+	}
+
+	return &iam.CreateIamTokenResponse{
+		IamToken:  tokenResponse.AccessToken,
+		ExpiresAt: timestamppb.New(time.Now().Add(expiresIn)),
+	}, nil
+}
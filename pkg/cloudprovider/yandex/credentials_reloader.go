@@ -0,0 +1,194 @@
+package yandex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	ycsdk "github.com/yandex-cloud/go-sdk"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how often the key file is checked for changes
+	credentialsReloadPeriod = 30 * time.Second
+	// IAM tokens are refreshed when they are about to expire in less than this
+	iamTokenRefreshMargin = 5 * time.Minute
+)
+
+// reloadingCredentials serves IAM tokens issued for a service account key stored in a file
+// and swaps the key atomically once the file changes.
+//
+// The SDK caches IAM tokens until they expire, so tokens are handed out with a short expiration time
+// and the SDK comes back for a token issued with the new key shortly after a rotation.
+// Requests that are already in flight keep their tokens and are not interrupted.
+type reloadingCredentials struct {
+	path string
+
+	current  atomic.Pointer[credentialsGeneration]
+	tokenSvc atomic.Pointer[iam.IamTokenServiceClient]
+
+	lastContent []byte
+	// failedContent is the content of the key file the last reload failed on,
+	// failures are only counted once per change of the file
+	failedContent []byte
+	failed        bool
+}
+
+type credentialsGeneration struct {
+	credentials ycsdk.Credentials
+
+	mu    sync.Mutex
+	token *iam.CreateIamTokenResponse
+}
+
+func newReloadingCredentials(path string, content []byte) (*reloadingCredentials, error) {
+	credentials, err := serviceAccountKeyCredentials(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &reloadingCredentials{
+		path:        path,
+		lastContent: content,
+	}
+	rc.current.Store(&credentialsGeneration{credentials: credentials})
+
+	return rc, nil
+}
+
+func (rc *reloadingCredentials) YandexCloudAPICredentials() {}
+
+// SetIAMTokenService sets the client used to exchange service account keys for IAM tokens.
+// The token service does not require authentication itself, so it is safe to use the SDK built with these credentials.
+func (rc *reloadingCredentials) SetIAMTokenService(tokenSvc iam.IamTokenServiceClient) {
+	rc.tokenSvc.Store(&tokenSvc)
+}
+
+func (rc *reloadingCredentials) IAMToken(ctx context.Context) (*iam.CreateIamTokenResponse, error) {
+	token, err := rc.current.Load().iamToken(ctx, rc.loadTokenSvc())
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := token.ExpiresAt.AsTime()
+	if maxExpiresAt := time.Now().Add(credentialsReloadPeriod); expiresAt.After(maxExpiresAt) {
+		expiresAt = maxExpiresAt
+	}
+
+	return &iam.CreateIamTokenResponse{
+		IamToken:  token.IamToken,
+		ExpiresAt: timestamppb.New(expiresAt),
+	}, nil
+}
+
+// Run watches the key file until stop is closed.
+func (rc *reloadingCredentials) Run(stop <-chan struct{}) {
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := rc.reload(ctx); err != nil {
+			klog.Errorf("failed to reload service account key from %q, keeping the previous key: %s", rc.path, err)
+		}
+	}, credentialsReloadPeriod, stop)
+}
+
+func (rc *reloadingCredentials) reload(ctx context.Context) error {
+	content, err := os.ReadFile(rc.path)
+	if err == nil && bytes.Equal(content, rc.lastContent) {
+		rc.failed = false
+		return nil
+	}
+
+	if err == nil {
+		err = rc.swap(ctx, content)
+	}
+	if err != nil {
+		if !rc.failed || !bytes.Equal(content, rc.failedContent) {
+			credentialsRotations.WithLabelValues("failure").Inc()
+		}
+		rc.failed, rc.failedContent = true, content
+		return err
+	}
+
+	rc.failed = false
+	credentialsRotations.WithLabelValues("success").Inc()
+	klog.Infof("service account key reloaded from %q", rc.path)
+
+	return nil
+}
+
+// swap switches to the key if it can be exchanged for an IAM token.
+func (rc *reloadingCredentials) swap(ctx context.Context, content []byte) error {
+	credentials, err := serviceAccountKeyCredentials(string(content))
+	if err != nil {
+		return err
+	}
+
+	// make sure that the new key actually works before switching to it
+	generation := &credentialsGeneration{credentials: credentials}
+	if _, err := generation.iamToken(ctx, rc.loadTokenSvc()); err != nil {
+		return errors.Wrap(err, "new service account key can't be exchanged for an IAM token")
+	}
+
+	rc.current.Store(generation)
+	rc.lastContent = content
+
+	return nil
+}
+
+func (rc *reloadingCredentials) loadTokenSvc() iam.IamTokenServiceClient {
+	tokenSvc := rc.tokenSvc.Load()
+	if tokenSvc == nil {
+		return nil
+	}
+
+	return *tokenSvc
+}
+
+func (g *credentialsGeneration) iamToken(ctx context.Context, tokenSvc iam.IamTokenServiceClient) (*iam.CreateIamTokenResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.token != nil && time.Until(g.token.ExpiresAt.AsTime()) > iamTokenRefreshMargin {
+		return g.token, nil
+	}
+
+	var (
+		token *iam.CreateIamTokenResponse
+		err   error
+	)
+	switch credentials := g.credentials.(type) {
+	case ycsdk.ExchangeableCredentials:
+		if tokenSvc == nil {
+			return nil, errors.New("IAM token service is not set")
+		}
+
+		var req *iam.CreateIamTokenRequest
+		req, err = credentials.IAMTokenRequest()
+		if err != nil {
+			return nil, err
+		}
+		token, err = tokenSvc.Create(ctx, req)
+	case ycsdk.NonExchangeableCredentials:
+		token, err = credentials.IAMToken(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported credentials type %T", credentials)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g.token = token
+
+	return token, nil
+}
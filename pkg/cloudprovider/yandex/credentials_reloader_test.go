@@ -0,0 +1,129 @@
+package yandex
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+// fakeIAMTokenService issues tokens equal to the key ID the JWT was signed with.
+type fakeIAMTokenService struct {
+	iam.IamTokenServiceClient
+}
+
+func (f *fakeIAMTokenService) Create(_ context.Context, in *iam.CreateIamTokenRequest, _ ...grpc.CallOption) (*iam.CreateIamTokenResponse, error) {
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(in.GetJwt(), ".")[0])
+	if err != nil {
+		return nil, err
+	}
+	var jwtHeader struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(header, &jwtHeader); err != nil {
+		return nil, err
+	}
+
+	return &iam.CreateIamTokenResponse{
+		IamToken:  jwtHeader.Kid,
+		ExpiresAt: timestamppb.New(time.Now().Add(12 * time.Hour)),
+	}, nil
+}
+
+func generateServiceAccountKeyJSON(t *testing.T, keyID string) []byte {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"id":                 keyID,
+		"service_account_id": "sa-id",
+		"private_key":        string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestReloadingCredentials(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	initialKey := generateServiceAccountKeyJSON(t, "key-1")
+	if err := os.WriteFile(keyFile, initialKey, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := newReloadingCredentials(keyFile, initialKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.SetIAMTokenService(&fakeIAMTokenService{})
+
+	ctx := context.Background()
+	token, err := rc.IAMToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IamToken != "key-1" {
+		t.Errorf("expected token issued for key-1, got %q", token.IamToken)
+	}
+	if time.Until(token.ExpiresAt.AsTime()) > credentialsReloadPeriod {
+		t.Errorf("tokens should be handed out with a short expiration time, got %s", token.ExpiresAt.AsTime())
+	}
+
+	// broken keys are not picked up
+	if err := os.WriteFile(keyFile, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registerMetrics()
+	failures := func() float64 {
+		value, err := testutil.GetCounterMetricValue(credentialsRotations.WithLabelValues("failure"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	failuresBefore := failures()
+	for i := 0; i < 2; i++ {
+		if err := rc.reload(ctx); err == nil {
+			t.Error("should return non-nil err on malformed key")
+		}
+	}
+	if actual := failures() - failuresBefore; actual != 1 {
+		t.Errorf("failures should be counted once per change of the key file, got %v", actual)
+	}
+
+	if err := os.WriteFile(keyFile, generateServiceAccountKeyJSON(t, "key-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err = rc.IAMToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IamToken != "key-2" {
+		t.Errorf("expected token issued for key-2 after rotation, got %q", token.IamToken)
+	}
+}
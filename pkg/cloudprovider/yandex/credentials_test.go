@@ -0,0 +1,87 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIAMTokenFileCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("t1.token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := newCredentials(&cloudConfigFile{Credentials: credentialsConfig{Type: credentialsTypeIAMTokenFile, IAMTokenFile: tokenFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := creds.(*iamTokenFileCredentials).IAMToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IamToken != "t1.token" {
+		t.Errorf("unexpected token %q", token.IamToken)
+	}
+	if token.ExpiresAt == nil {
+		t.Error("token should have an expiration time")
+	}
+}
+
+func TestWorkloadIdentityCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.Form.Get("audience") != "sa-id" || r.Form.Get("subject_token") != "k8s-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"access_token": "iam-token", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("k8s-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := newCredentials(&cloudConfigFile{Credentials: credentialsConfig{
+		Type: credentialsTypeWorkloadIdentityFederation,
+		WorkloadIdentityFederation: workloadIdentityFederationConfig{
+			ServiceAccountID: "sa-id",
+			TokenFile:        tokenFile,
+			Endpoint:         server.URL,
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := creds.(*workloadIdentityCredentials).IAMToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IamToken != "iam-token" {
+		t.Errorf("unexpected token %q", token.IamToken)
+	}
+}
+
+func TestNewCredentialsValidation(t *testing.T) {
+	for _, cfg := range []credentialsConfig{
+		{},
+		{Type: "Unknown"},
+		{Type: credentialsTypeIAMTokenFile},
+		{Type: credentialsTypeOAuthToken},
+		{Type: credentialsTypeWorkloadIdentityFederation},
+	} {
+		if _, err := newCredentials(&cloudConfigFile{Credentials: cfg}); err == nil {
+			t.Errorf("should return non-nil err for %+v", cfg)
+		}
+	}
+}
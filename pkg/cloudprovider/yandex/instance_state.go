@@ -0,0 +1,75 @@
+package yandex
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"k8s.io/klog/v2"
+)
+
+// defaultUserStopMarker is the instance label or metadata key that marks preemptible instances stopped on purpose.
+const defaultUserStopMarker = "ccm-user-stop"
+
+// instanceState is the state of an instance as seen by the node lifecycle controller.
+type instanceState int
+
+const (
+	instanceStateRunning instanceState = iota
+	// instanceStateShutdown is a stopped, crashed or failed instance, or one that is going down.
+	instanceStateShutdown
+	// instanceStatePreempted is a preemptible instance stopped by Yandex.Cloud rather than by a user.
+	instanceStatePreempted
+)
+
+func (s instanceState) isShutdown() bool {
+	return s == instanceStateShutdown || s == instanceStatePreempted
+}
+
+func (yc *Cloud) instanceState(ctx context.Context, instance *compute.Instance) instanceState {
+	switch instance.Status {
+	case compute.Instance_STOPPING, compute.Instance_STOPPED:
+		if instance.GetSchedulingPolicy().GetPreemptible() && !yc.isStoppedByUser(ctx, instance) {
+			return instanceStatePreempted
+		}
+
+		return instanceStateShutdown
+	case compute.Instance_CRASHED, compute.Instance_ERROR, compute.Instance_DELETING:
+		return instanceStateShutdown
+	default:
+		// the instance may be stopped again with a different marker
+		yc.userStops.Delete(instance.Id)
+		return instanceStateRunning
+	}
+}
+
+// isStoppedByUser tells an intentional stop of a preemptible instance from a preemption.
+// Whoever stops the instance is expected to set the marker in its labels or metadata beforehand.
+func (yc *Cloud) isStoppedByUser(ctx context.Context, instance *compute.Instance) bool {
+	marker := yc.config.UserStopMarker
+	if len(marker) == 0 {
+		marker = defaultUserStopMarker
+	}
+
+	if _, ok := instance.Labels[marker]; ok {
+		return true
+	}
+
+	if instance.Metadata != nil {
+		_, ok := instance.Metadata[marker]
+		return ok
+	}
+
+	if stopped, ok := yc.userStops.Load(instance.Id); ok {
+		return stopped.(bool)
+	}
+	metadata, err := yc.yandexService.ComputeSvc.GetInstanceMetadata(ctx, instance.Id)
+	if err != nil {
+		// treating the instance as preempted is the safe choice, it is down either way
+		klog.Errorf("failed to get metadata of Instance %q: %s", instance.Name, err)
+		return false
+	}
+	_, ok := metadata[marker]
+	yc.userStops.Store(instance.Id, ok)
+
+	return ok
+}
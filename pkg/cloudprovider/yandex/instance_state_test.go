@@ -0,0 +1,114 @@
+package yandex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+func TestInstanceState(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      compute.Instance_Status
+		preemptible bool
+		labels      map[string]string
+		metadata    map[string]string
+		expected    instanceState
+	}{
+		{"running", compute.Instance_RUNNING, false, nil, nil, instanceStateRunning},
+		{"starting", compute.Instance_STARTING, true, nil, nil, instanceStateRunning},
+		{"stopping", compute.Instance_STOPPING, false, nil, nil, instanceStateShutdown},
+		{"stopped", compute.Instance_STOPPED, false, nil, nil, instanceStateShutdown},
+		{"crashed", compute.Instance_CRASHED, false, nil, nil, instanceStateShutdown},
+		{"error", compute.Instance_ERROR, false, nil, nil, instanceStateShutdown},
+		{"preempted", compute.Instance_STOPPED, true, nil, nil, instanceStatePreempted},
+		{"preempting", compute.Instance_STOPPING, true, nil, nil, instanceStatePreempted},
+		{"stopped by user via label", compute.Instance_STOPPED, true, map[string]string{defaultUserStopMarker: ""}, nil, instanceStateShutdown},
+		{"stopped by user via metadata", compute.Instance_STOPPED, true, nil, map[string]string{defaultUserStopMarker: "true"}, instanceStateShutdown},
+		{"crashed preemptible", compute.Instance_CRASHED, true, nil, nil, instanceStateShutdown},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			instance := newTestInstance("id1", "vm1")
+			instance.Status = tc.status
+			instance.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: tc.preemptible}
+			instance.Labels = tc.labels
+			instance.Metadata = tc.metadata
+
+			yc := newTestCloud(&fakeInstanceService{instances: []*compute.Instance{instance}})
+			if state := yc.instanceState(context.Background(), instance); state != tc.expected {
+				t.Errorf("expected state %d, got %d", tc.expected, state)
+			}
+		})
+	}
+}
+
+func TestUserStopMarkerCache(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.Status = compute.Instance_STOPPED
+	instance.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+	instanceSvc := &fakeInstanceService{instances: []*compute.Instance{instance}}
+	yc := newTestCloud(instanceSvc)
+	ctx := context.Background()
+
+	// listed instances come without metadata
+	listed := newTestInstance("id1", "vm1")
+	listed.Status = compute.Instance_STOPPED
+	listed.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+	for i := 0; i < 3; i++ {
+		if state := yc.instanceState(ctx, listed); state != instanceStatePreempted {
+			t.Errorf("expected the instance to be preempted, got %d", state)
+		}
+	}
+	if instanceSvc.calls != 1 {
+		t.Errorf("expected the metadata to be requested once, got %d API calls", instanceSvc.calls)
+	}
+
+	// the marker is looked up again once the instance has been running
+	listed.Status = compute.Instance_RUNNING
+	yc.instanceState(ctx, listed)
+	listed.Status = compute.Instance_STOPPED
+	instance.Metadata = map[string]string{defaultUserStopMarker: "true"}
+	if state := yc.instanceState(ctx, listed); state != instanceStateShutdown {
+		t.Errorf("expected the instance to be stopped by a user, got %d", state)
+	}
+	if instanceSvc.calls != 2 {
+		t.Errorf("expected the metadata to be requested again, got %d API calls", instanceSvc.calls)
+	}
+}
+
+func TestInstanceExistsPreempted(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.Status = compute.Instance_STOPPED
+	instance.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+
+	yc := newTestCloud(&fakeInstanceService{instances: []*compute.Instance{instance}})
+	ctx := context.Background()
+
+	exists, err := yc.InstanceExistsByProviderID(ctx, "yandex://id1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("preempted instances should exist by default")
+	}
+
+	shutdown, err := yc.InstanceShutdownByProviderID(ctx, "yandex://id1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shutdown {
+		t.Error("preempted instances should be shut down")
+	}
+
+	yc.config.ReportPreemptedAsNonExistent = true
+	exists, err = yc.InstanceExistsByProviderID(ctx, "yandex://id1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("preempted instances should not exist if configured so")
+	}
+}
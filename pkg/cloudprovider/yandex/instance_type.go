@@ -0,0 +1,74 @@
+package yandex
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"k8s.io/klog/v2"
+)
+
+// defaultInstanceTypeFormat produces instance types like "standard-v3.4c-16g-100" or "gpu-standard-v3.8c-96g-100-1gpu".
+const defaultInstanceTypeFormat = `{{.PlatformID}}.{{.Cores}}c-{{.MemoryGB}}g-{{.CoreFraction}}{{if .GPUs}}-{{.GPUs}}gpu{{end}}`
+
+// maximum length of a label value, the instance type ends up in the node.kubernetes.io/instance-type label
+const maxInstanceTypeLength = 63
+
+var invalidInstanceTypeChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// instanceTypeParameters are the fields available in the instance type format template.
+type instanceTypeParameters struct {
+	PlatformID   string
+	Cores        int64
+	CoreFraction int64
+	// MemoryGB is the amount of memory in GiB, fractional amounts are formatted like "0.5"
+	MemoryGB    string
+	MemoryBytes int64
+	GPUs        int64
+}
+
+func parseInstanceTypeFormat(format string) (*template.Template, error) {
+	if format == "" {
+		format = defaultInstanceTypeFormat
+	}
+
+	tmpl, err := template.New("instanceType").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed instance type format %q", format)
+	}
+
+	return tmpl, nil
+}
+
+func (yc *Cloud) instanceType(instance *compute.Instance) string {
+	params := instanceTypeParameters{PlatformID: instance.PlatformId}
+	if resources := instance.Resources; resources != nil {
+		params.Cores = resources.Cores
+		params.CoreFraction = resources.CoreFraction
+		params.MemoryBytes = resources.Memory
+		params.MemoryGB = strconv.FormatFloat(float64(resources.Memory)/(1<<30), 'f', -1, 64)
+		params.GPUs = resources.Gpus
+	}
+
+	var buf bytes.Buffer
+	if err := yc.config.instanceTypeTemplate.Execute(&buf, params); err != nil {
+		klog.Errorf("failed to format instance type for instance %q: %s", instance.Id, err)
+		return ""
+	}
+
+	return sanitizeInstanceType(buf.String())
+}
+
+// sanitizeInstanceType makes the instance type a valid label value.
+func sanitizeInstanceType(instanceType string) string {
+	instanceType = invalidInstanceTypeChars.ReplaceAllString(instanceType, "-")
+	if len(instanceType) > maxInstanceTypeLength {
+		instanceType = instanceType[:maxInstanceTypeLength]
+	}
+
+	return strings.Trim(instanceType, "-_.")
+}
@@ -0,0 +1,66 @@
+package yandex
+
+import (
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+func TestInstanceType(t *testing.T) {
+	instance := &compute.Instance{
+		Id:         "id",
+		PlatformId: "standard-v3",
+		Resources: &compute.Resources{
+			Cores:        4,
+			CoreFraction: 100,
+			Memory:       16 << 30,
+		},
+	}
+	gpuInstance := &compute.Instance{
+		Id:         "id",
+		PlatformId: "gpu-standard-v3",
+		Resources: &compute.Resources{
+			Cores:        8,
+			CoreFraction: 100,
+			Memory:       96 << 30,
+			Gpus:         1,
+		},
+	}
+	burstableInstance := &compute.Instance{
+		Id:         "id",
+		PlatformId: "standard-v2",
+		Resources: &compute.Resources{
+			Cores:        2,
+			CoreFraction: 5,
+			Memory:       1 << 29,
+		},
+	}
+
+	testCases := []struct {
+		format   string
+		instance *compute.Instance
+		expected string
+	}{
+		{"", instance, "standard-v3.4c-16g-100"},
+		{"", gpuInstance, "gpu-standard-v3.8c-96g-100-1gpu"},
+		{"", burstableInstance, "standard-v2.2c-0.5g-5"},
+		{"{{.PlatformID}}/{{.Cores}}", instance, "standard-v3-4"},
+		{"{{.MemoryBytes}}", instance, "17179869184"},
+	}
+
+	for _, tc := range testCases {
+		tmpl, err := parseInstanceTypeFormat(tc.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cloud := &Cloud{config: CloudConfig{instanceTypeTemplate: tmpl}}
+		if actual := cloud.instanceType(tc.instance); actual != tc.expected {
+			t.Errorf("format %q: expected %q, got %q", tc.format, tc.expected, actual)
+		}
+	}
+
+	if _, err := parseInstanceTypeFormat("{{.PlatformID"); err == nil {
+		t.Error("should return non-nil err on malformed format")
+	}
+}
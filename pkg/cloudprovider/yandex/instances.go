@@ -3,16 +3,20 @@ package yandex
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
-	"github.com/pkg/errors"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
-	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
 )
 
 func (yc *Cloud) NodeAddresses(ctx context.Context, nodeName types.NodeName) ([]v1.NodeAddress, error) {
@@ -42,12 +46,22 @@ func (yc *Cloud) InstanceID(ctx context.Context, nodeName types.NodeName) (strin
 	return instance.Id, nil
 }
 
-func (yc *Cloud) InstanceType(_ context.Context, _ types.NodeName) (string, error) {
-	return "", nil
+func (yc *Cloud) InstanceType(ctx context.Context, nodeName types.NodeName) (string, error) {
+	instance, err := yc.getInstanceByNodeName(ctx, nodeName)
+	if err != nil {
+		return "", err
+	}
+
+	return yc.instanceType(instance), nil
 }
 
-func (yc *Cloud) InstanceTypeByProviderID(_ context.Context, _ string) (string, error) {
-	return "", nil
+func (yc *Cloud) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
+	instance, err := yc.getInstanceByProviderID(ctx, providerID)
+	if err != nil {
+		return "", err
+	}
+
+	return yc.instanceType(instance), nil
 }
 
 func (yc *Cloud) AddSSHKeyToAllInstances(_ context.Context, _ string, _ []byte) error {
@@ -59,7 +73,12 @@ func (yc *Cloud) CurrentNodeName(_ context.Context, hostName string) (types.Node
 }
 
 func (yc *Cloud) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
-	_, err := yc.getInstanceByProviderID(ctx, providerID)
+	if yc.config.ReportPreemptedAsNonExistent {
+		// preemption must be noticed as soon as possible
+		ctx = yapi.WithCacheBypass(ctx)
+	}
+
+	instance, err := yc.getInstanceByProviderID(ctx, providerID)
 	if err != nil {
 		if err == cloudprovider.InstanceNotFound {
 			return false, nil
@@ -68,16 +87,27 @@ func (yc *Cloud) InstanceExistsByProviderID(ctx context.Context, providerID stri
 		return false, err
 	}
 
-	return true, nil
+	return yc.instanceExists(ctx, instance), nil
 }
 
 func (yc *Cloud) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
-	instance, err := yc.getInstanceByProviderID(ctx, providerID)
+	instance, err := yc.getInstanceByProviderID(yapi.WithCacheBypass(ctx), providerID)
 	if err != nil {
 		return false, err
 	}
 
-	return instance.Status == compute.Instance_STOPPED, nil
+	return yc.instanceState(ctx, instance).isShutdown(), nil
+}
+
+// instanceExists reports preempted instances as non-existent if configured to,
+// so that their Nodes get deleted and the pods are rescheduled right away.
+func (yc *Cloud) instanceExists(ctx context.Context, instance *compute.Instance) bool {
+	if yc.config.ReportPreemptedAsNonExistent && yc.instanceState(ctx, instance) == instanceStatePreempted {
+		klog.Infof("Instance %q has been preempted, reporting it as non-existent", instance.Name)
+		return false
+	}
+
+	return true
 }
 
 func (yc *Cloud) extractNodeAddresses(ctx context.Context, instance *compute.Instance) ([]v1.NodeAddress, error) {
@@ -85,54 +115,95 @@ func (yc *Cloud) extractNodeAddresses(ctx context.Context, instance *compute.Ins
 		return nil, fmt.Errorf("could not find network interfaces for instance: folderID=%s, name=%s", instance.FolderId, instance.Name)
 	}
 
-	var nodeAddresses []v1.NodeAddress
-
-	if len(yc.config.InternalNetworkIDsSet) > 0 {
-		for _, iface := range instance.NetworkInterfaces {
-			networkID, err := mapSubnetIdToNetworkID(ctx, yc.yandexService.VPCSvc.SubnetSvc, iface.SubnetId)
-			if err != nil {
-				return nil, err
-			}
+	interfaceAddresses, err := yc.classifyInterfaceAddresses(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	// addresses that match no rule are skipped, like the addresses of networks not listed as internal used to be
+	if len(interfaceAddresses) == 0 {
+		return nil, fmt.Errorf("could not find primary IPv4 or IPv6 address for instance: folderID=%s, name=%s", instance.FolderId, instance.Name)
+	}
 
-			if _, ok := yc.config.InternalNetworkIDsSet[networkID]; ok {
-				nodeAddresses = append(nodeAddresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: iface.PrimaryV4Address.Address})
+	// InternalIPs of all interfaces are listed before ExternalIPs
+	var nodeAddresses []v1.NodeAddress
+	for _, addressType := range []v1.NodeAddressType{v1.NodeInternalIP, v1.NodeExternalIP} {
+		for _, address := range interfaceAddresses {
+			if v1.NodeAddressType(address.Type) == addressType {
+				nodeAddresses = appendNodeAddresses(nodeAddresses, addressType, address.Address)
 			}
 		}
-	} else {
-		networkInterface := instance.NetworkInterfaces[0]
-		if networkInterface.PrimaryV4Address == nil {
-			return nil, fmt.Errorf("could not find primary IPv4 address for instance: folderID=%s, name=%s", instance.FolderId, instance.Name)
-		}
-
-		nodeAddresses = []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: networkInterface.PrimaryV4Address.Address}}
-		if networkInterface.PrimaryV4Address.OneToOneNat != nil {
-			nodeAddresses = append(nodeAddresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: networkInterface.PrimaryV4Address.OneToOneNat.Address})
+	}
+	if !yc.hasExternalAddressRules() {
+		// use one-to-one NAT addresses of the first interface that has them, separately for each IP family
+		for _, primaryAddress := range []func(*compute.NetworkInterface) *compute.PrimaryAddress{
+			(*compute.NetworkInterface).GetPrimaryV4Address,
+			(*compute.NetworkInterface).GetPrimaryV6Address,
+		} {
+			for _, iface := range instance.NetworkInterfaces {
+				if natAddress := primaryAddress(iface).GetOneToOneNat().GetAddress(); len(natAddress) > 0 {
+					nodeAddresses = appendNodeAddresses(nodeAddresses, v1.NodeExternalIP, natAddress)
+					break
+				}
+			}
 		}
 	}
 
-	if len(yc.config.ExternalNetworkIDsSet) > 0 {
-		for _, iface := range instance.NetworkInterfaces {
-			networkID, err := mapSubnetIdToNetworkID(ctx, yc.yandexService.VPCSvc.SubnetSvc, iface.SubnetId)
-			if err != nil {
-				return nil, err
-			}
+	nodeAddresses = sortNodeAddressesByIPFamily(nodeAddresses, yc.config.PrimaryIPFamily)
 
-			if _, ok := yc.config.ExternalNetworkIDsSet[networkID]; ok {
-				nodeAddresses = append(nodeAddresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: iface.PrimaryV4Address.Address})
-			}
+	if fqdn := instance.Fqdn; len(fqdn) > 0 {
+		if yc.config.ReportHostnameAddress {
+			hostname, _, _ := strings.Cut(fqdn, ".")
+			nodeAddresses = appendNodeAddresses(nodeAddresses, v1.NodeHostName, hostname)
 		}
-	} else {
-		for _, iface := range instance.NetworkInterfaces {
-			if iface.PrimaryV4Address.OneToOneNat != nil {
-				nodeAddresses = append(nodeAddresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: iface.PrimaryV4Address.OneToOneNat.Address})
-				break
-			}
+		if yc.config.ReportInternalDNSAddress {
+			nodeAddresses = appendNodeAddresses(nodeAddresses, v1.NodeInternalDNS, fqdn)
 		}
 	}
 
 	return nodeAddresses, nil
 }
 
+// interfacePrimaryAddresses returns the interface's primary IPv4 and IPv6 addresses, whichever are present.
+func interfacePrimaryAddresses(iface *compute.NetworkInterface) (addresses []string) {
+	if address := iface.GetPrimaryV4Address().GetAddress(); len(address) > 0 {
+		addresses = append(addresses, address)
+	}
+	if address := iface.GetPrimaryV6Address().GetAddress(); len(address) > 0 {
+		addresses = append(addresses, address)
+	}
+
+	return
+}
+
+func appendNodeAddresses(nodeAddresses []v1.NodeAddress, addressType v1.NodeAddressType, addresses ...string) []v1.NodeAddress {
+	for _, address := range addresses {
+		nodeAddresses = append(nodeAddresses, v1.NodeAddress{Type: addressType, Address: address})
+	}
+
+	return nodeAddresses
+}
+
+// sortNodeAddressesByIPFamily moves addresses of the primary IP family to the front, keeping the order otherwise.
+func sortNodeAddressesByIPFamily(nodeAddresses []v1.NodeAddress, primaryIPFamily v1.IPFamily) []v1.NodeAddress {
+	sort.SliceStable(nodeAddresses, func(i, j int) bool {
+		return ipFamilyOf(nodeAddresses[i].Address) == primaryIPFamily && ipFamilyOf(nodeAddresses[j].Address) != primaryIPFamily
+	})
+
+	return nodeAddresses
+}
+
+func ipFamilyOf(address string) v1.IPFamily {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return v1.IPFamilyUnknown
+	case ip.To4() != nil:
+		return v1.IPv4Protocol
+	default:
+		return v1.IPv6Protocol
+	}
+}
+
 func (yc *Cloud) getInstanceByProviderID(ctx context.Context, providerID string) (*compute.Instance, error) {
 	instanceName, instanceNameIsId, err := ParseProviderID(providerID)
 	if err != nil {
@@ -140,7 +211,7 @@ func (yc *Cloud) getInstanceByProviderID(ctx context.Context, providerID string)
 	}
 
 	if instanceNameIsId {
-		instance, err := yc.yandexService.ComputeSvc.InstanceSvc.Get(ctx, &compute.GetInstanceRequest{InstanceId: instanceName})
+		instance, err := yc.yandexService.ComputeSvc.GetInstanceByID(ctx, instanceName)
 		if err != nil {
 			if status.Code(err) == codes.NotFound {
 				return nil, cloudprovider.InstanceNotFound
@@ -150,7 +221,7 @@ func (yc *Cloud) getInstanceByProviderID(ctx context.Context, providerID string)
 		return instance, nil
 	}
 
-	instance, err := yc.yandexService.ComputeSvc.FindInstanceByName(ctx, instanceName)
+	instance, err := yc.yandexService.ComputeSvc.FindInstanceByFolderAndName(ctx, providerIDFolder(providerID), instanceName)
 	if err != nil {
 		return nil, err
 	}
@@ -160,27 +231,3 @@ func (yc *Cloud) getInstanceByProviderID(ctx context.Context, providerID string)
 
 	return instance, nil
 }
-
-func (yc *Cloud) getInstanceByNodeName(ctx context.Context, nodeName types.NodeName) (*compute.Instance, error) {
-	instanceName := MapNodeNameToInstanceName(nodeName)
-
-	instance, err := yc.yandexService.ComputeSvc.FindInstanceByName(ctx, instanceName)
-	if err != nil {
-		return nil, err
-	}
-	if instance == nil {
-		return nil, cloudprovider.InstanceNotFound
-	}
-
-	return instance, nil
-}
-
-// TODO: move?
-func mapSubnetIdToNetworkID(ctx context.Context, vpcSdk vpc.SubnetServiceClient, subnetID string) (string, error) {
-	subnet, err := vpcSdk.Get(ctx, &vpc.GetSubnetRequest{SubnetId: subnetID})
-	if err != nil {
-		return "", errors.WithStack(err)
-	}
-
-	return subnet.NetworkId, nil
-}
@@ -0,0 +1,243 @@
+package yandex
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+func TestExtractNodeAddressesDualStack(t *testing.T) {
+	instance := &compute.Instance{
+		Id:   "id",
+		Name: "name",
+		NetworkInterfaces: []*compute.NetworkInterface{
+			{
+				SubnetId: "subnet1",
+				PrimaryV4Address: &compute.PrimaryAddress{
+					Address:     "10.0.0.1",
+					OneToOneNat: &compute.OneToOneNat{Address: "1.2.3.4"},
+				},
+				PrimaryV6Address: &compute.PrimaryAddress{
+					Address: "fd00::1",
+				},
+			},
+			{
+				SubnetId: "subnet2",
+				PrimaryV6Address: &compute.PrimaryAddress{
+					Address:     "fd01::1",
+					OneToOneNat: &compute.OneToOneNat{Address: "2a02::1"},
+				},
+			},
+		},
+	}
+
+	cloud := newTestCloud(&fakeInstanceService{})
+	addresses, err := cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+		{Type: v1.NodeInternalIP, Address: "fd00::1"},
+		{Type: v1.NodeExternalIP, Address: "2a02::1"},
+	}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+
+	cloud.config.PrimaryIPFamily = v1.IPv6Protocol
+	addresses, err = cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "fd00::1"},
+		{Type: v1.NodeExternalIP, Address: "2a02::1"},
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+	}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+
+	// IPv6-only interfaces must not break address extraction
+	instance.NetworkInterfaces = instance.NetworkInterfaces[1:]
+	addresses, err = cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 {
+		t.Errorf("expected IPv6 internal and external addresses, got %v", addresses)
+	}
+
+	instance.NetworkInterfaces = []*compute.NetworkInterface{{SubnetId: "subnet3"}}
+	if _, err = cloud.extractNodeAddresses(context.Background(), instance); err == nil {
+		t.Error("should return non-nil err for an interface without addresses")
+	}
+}
+
+func TestExtractNodeAddressesFQDN(t *testing.T) {
+	instance := newTestInstance("id", "name")
+	instance.Fqdn = "node1.ru-central1.internal"
+
+	cloud := newTestCloud(&fakeInstanceService{})
+	addresses, err := cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 {
+		t.Errorf("DNS addresses should not be reported by default, got %v", addresses)
+	}
+
+	cloud.config.ReportHostnameAddress = true
+	cloud.config.ReportInternalDNSAddress = true
+	addresses, err = cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+		{Type: v1.NodeHostName, Address: "node1"},
+		{Type: v1.NodeInternalDNS, Address: "node1.ru-central1.internal"},
+	}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+}
+
+func TestExtractNodeAddressesRules(t *testing.T) {
+	instance := &compute.Instance{
+		Id:   "id",
+		Name: "name",
+		NetworkInterfaces: []*compute.NetworkInterface{
+			{
+				Index:    "0",
+				SubnetId: "subnet1",
+				PrimaryV4Address: &compute.PrimaryAddress{
+					Address:     "10.0.0.1",
+					OneToOneNat: &compute.OneToOneNat{Address: "1.2.3.4"},
+				},
+			},
+			{
+				Index:            "1",
+				SubnetId:         "storage-subnet",
+				PrimaryV4Address: &compute.PrimaryAddress{Address: "10.1.0.5"},
+			},
+			{
+				Index:            "2",
+				SubnetId:         "public-subnet",
+				PrimaryV4Address: &compute.PrimaryAddress{Address: "84.201.0.10"},
+			},
+		},
+	}
+
+	secondInterface := 1
+	rules, err := parseAddressRules([]addressRule{
+		{CIDR: "10.1.0.0/16", Type: addressRuleSkip},
+		{InterfaceIndex: &secondInterface, Type: string(v1.NodeInternalIP)},
+		{SubnetID: "public-subnet", Type: string(v1.NodeExternalIP)},
+		{Type: string(v1.NodeInternalIP)},
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cloud := newTestCloud(&fakeInstanceService{})
+	cloud.config.AddressRules = rules
+
+	addresses, err := cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the storage interface is skipped by the first rule, NAT is not used since ExternalIPs are selected by rules
+	expected := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "84.201.0.10"},
+	}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+
+	// instances without matching addresses keep reporting the other addresses
+	cloud.config.AddressRules = legacyAddressRules([]string{"other-network"}, nil)
+	cloud.yandexService.VPCSvc = yapi.NewVPCService(nil, &fakeSubnetService{networkID: "network"}, nil, nil, &yapi.CloudContext{})
+	addresses, err = cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "1.2.3.4"}}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+
+	if _, err := parseAddressRules([]addressRule{{Type: "PublicIP"}}, nil, nil); err == nil {
+		t.Error("should return non-nil err on unknown rule type")
+	}
+	if _, err := parseAddressRules([]addressRule{{CIDR: "10.0.0.0", Type: addressRuleSkip}}, nil, nil); err == nil {
+		t.Error("should return non-nil err on malformed CIDR")
+	}
+}
+
+func TestExtractNodeAddressesLegacyExternalNetworks(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.NetworkInterfaces[0].PrimaryV4Address.OneToOneNat = &compute.OneToOneNat{Address: "1.2.3.4"}
+
+	// the defaults of the chart: no internal networks and the network of the first interface is external
+	rules, err := parseAddressRules(nil, nil, []string{"network"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloud := newTestCloud(&fakeInstanceService{})
+	cloud.config.AddressRules = rules
+	cloud.yandexService.VPCSvc = yapi.NewVPCService(nil, &fakeSubnetService{networkID: "network"}, nil, nil, &yapi.CloudContext{})
+
+	addresses, err := cloud.extractNodeAddresses(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+	}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+}
+
+func TestLegacyAddressRulesOrder(t *testing.T) {
+	rules := legacyAddressRules([]string{"int2", " int1", "int2"}, []string{"ext3", "ext1", "ext2", "ext1", ""})
+
+	var actual []string
+	for _, rule := range rules {
+		actual = append(actual, rule.Type+"/"+rule.NetworkID)
+	}
+	expected := []string{"InternalIP/int2", "InternalIP/int1", "ExternalIP/ext3", "ExternalIP/ext1", "ExternalIP/ext2"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+// fakeSubnetService places all subnets in the same network, or fails all lookups with err.
+type fakeSubnetService struct {
+	vpc.SubnetServiceClient
+
+	networkID string
+	err       error
+}
+
+func (f *fakeSubnetService) Get(_ context.Context, in *vpc.GetSubnetRequest, _ ...grpc.CallOption) (*vpc.Subnet, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &vpc.Subnet{Id: in.SubnetId, NetworkId: f.networkID}, nil
+}
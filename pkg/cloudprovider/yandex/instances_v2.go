@@ -0,0 +1,144 @@
+package yandex
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+// InstanceExists returns true if the instance for the given node exists according to the cloud provider.
+// Instances replaced by another instance with the same name are reported as non-existent.
+func (yc *Cloud) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
+	if yc.instanceRecreated(ctx, node) {
+		return false, nil
+	}
+
+	if yc.config.ReportPreemptedAsNonExistent {
+		// preemption must be noticed as soon as possible
+		ctx = yapi.WithCacheBypass(ctx)
+	}
+
+	instance, err := yc.findInstanceByNode(ctx, node)
+	if err != nil {
+		if err == cloudprovider.InstanceNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return yc.instanceExists(ctx, instance), nil
+}
+
+// InstanceShutdown returns true if the instance is shutdown according to the cloud provider.
+func (yc *Cloud) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
+	// the status is checked only for NotReady Nodes, so it is always fetched from the API
+	instance, err := yc.getInstanceByNode(yapi.WithCacheBypass(ctx), node)
+	if err != nil {
+		return false, err
+	}
+
+	return yc.instanceState(ctx, instance).isShutdown(), nil
+}
+
+// InstanceMetadata returns the instance's metadata. All the values are derived from a single instance lookup.
+func (yc *Cloud) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
+	instance, err := yc.getInstanceByNode(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeAddresses, err := yc.extractNodeAddresses(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := yc.getZone(instance.ZoneId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudprovider.InstanceMetadata{
+		ProviderID:    instanceProviderID(instance),
+		InstanceType:  yc.instanceType(instance),
+		NodeAddresses: nodeAddresses,
+		Zone:          zone.FailureDomain,
+		Region:        zone.Region,
+
+		AdditionalLabels: yc.instanceNodeLabels(ctx, instance),
+	}, nil
+}
+
+// getInstanceByNode looks the instance up like findInstanceByNode,
+// but an instance replaced by another instance with the same name is not found.
+func (yc *Cloud) getInstanceByNode(ctx context.Context, node *v1.Node) (*compute.Instance, error) {
+	if len(yc.replacementInstanceID(ctx, node)) > 0 {
+		return nil, cloudprovider.InstanceNotFound
+	}
+
+	return yc.findInstanceByNode(ctx, node)
+}
+
+// findInstanceByNode looks the instance up by the Node's ProviderID, falling back to the Node's name
+// for Nodes that are not initialized yet.
+func (yc *Cloud) findInstanceByNode(ctx context.Context, node *v1.Node) (*compute.Instance, error) {
+	if len(node.Spec.ProviderID) > 0 {
+		return yc.getInstanceByProviderID(ctx, node.Spec.ProviderID)
+	}
+
+	return yc.getInstanceByNodeName(ctx, types.NodeName(node.Name))
+}
+
+// instanceRecreated tells whether another instance has taken the place of the Node's instance under the same name,
+// e.g. when the VM has been recreated by an autoscaler, and emits an event if so.
+func (yc *Cloud) instanceRecreated(ctx context.Context, node *v1.Node) bool {
+	newInstanceID := yc.replacementInstanceID(ctx, node)
+	if len(newInstanceID) == 0 {
+		return false
+	}
+
+	oldInstanceID, _, _ := ParseProviderID(node.Spec.ProviderID)
+	klog.Warningf("Instance of Node %q has been recreated: %q was replaced by %q", node.Name, oldInstanceID, newInstanceID)
+	if yc.recorder != nil {
+		yc.recorder.Eventf(node, v1.EventTypeWarning, "InstanceRecreated",
+			"Instance %q has been replaced by %q with the same name, the Node will be re-registered", oldInstanceID, newInstanceID)
+	}
+
+	return true
+}
+
+// replacementInstanceID returns the ID of the instance that the Node's name resolves to if it differs from the ProviderID.
+// The name is looked up in the cache first and a mismatch is confirmed by the API, as the cache may not have been refreshed yet.
+// The recreation can't be detected under the ProviderID name mapping, which resolves the name through the ProviderID itself.
+func (yc *Cloud) replacementInstanceID(ctx context.Context, node *v1.Node) string {
+	if yc.config.NodeNameMapping == nodeNameMappingProviderID {
+		return ""
+	}
+
+	instanceID, isID, err := ParseProviderID(node.Spec.ProviderID)
+	if err != nil || !isID {
+		return ""
+	}
+
+	instance, err := yc.getInstanceByNodeName(ctx, types.NodeName(node.Name))
+	if err != nil || instance.Id == instanceID {
+		return ""
+	}
+
+	instance, err = yc.getInstanceByNodeName(yapi.WithCacheBypass(ctx), types.NodeName(node.Name))
+	if err != nil || instance.Id == instanceID {
+		return ""
+	}
+
+	return instance.Id
+}
+
+func instanceProviderID(instance *compute.Instance) string {
+	return providerName + "://" + instance.Id
+}
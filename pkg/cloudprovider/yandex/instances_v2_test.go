@@ -0,0 +1,236 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+// fakeInstanceService serves instances from memory and counts API calls.
+type fakeInstanceService struct {
+	compute.InstanceServiceClient
+
+	instances []*compute.Instance
+	calls     int
+}
+
+func (f *fakeInstanceService) Get(_ context.Context, in *compute.GetInstanceRequest, _ ...grpc.CallOption) (*compute.Instance, error) {
+	f.calls++
+	for _, instance := range f.instances {
+		if instance.Id == in.InstanceId {
+			return instance, nil
+		}
+	}
+
+	return nil, status.Error(codes.NotFound, "instance not found")
+}
+
+func (f *fakeInstanceService) List(_ context.Context, in *compute.ListInstancesRequest, _ ...grpc.CallOption) (*compute.ListInstancesResponse, error) {
+	f.calls++
+	resp := &compute.ListInstancesResponse{}
+	for _, instance := range f.instances {
+		if in.Filter == "" || in.Filter == `name = "`+instance.Name+`"` {
+			resp.Instances = append(resp.Instances, instance)
+		}
+	}
+
+	return resp, nil
+}
+
+func newTestCloud(instanceSvc compute.InstanceServiceClient) *Cloud {
+	cloudCtx := &yapi.CloudContext{FolderID: "folder", RegionID: "ru-central1"}
+
+	instanceTypeTemplate, _ := parseInstanceTypeFormat("")
+
+	return NewCloud(CloudConfig{FolderID: "folder", PrimaryIPFamily: v1.IPv4Protocol, AddressRules: legacyAddressRules(nil, nil), instanceTypeTemplate: instanceTypeTemplate}, &yapi.YandexCloudAPI{
+		ComputeSvc: yapi.NewComputeService(instanceSvc, nil, cloudCtx),
+	})
+}
+
+func newTestInstance(id, name string) *compute.Instance {
+	return &compute.Instance{
+		Id:       id,
+		Name:     name,
+		FolderId: "folder",
+		ZoneId:   "ru-central1-a",
+		Status:   compute.Instance_RUNNING,
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			SubnetId: "subnet",
+			PrimaryV4Address: &compute.PrimaryAddress{
+				Address:     "10.0.0.1",
+				OneToOneNat: &compute.OneToOneNat{Address: "1.2.3.4"},
+			},
+		}},
+	}
+}
+
+func TestInstanceMetadata(t *testing.T) {
+	instanceSvc := &fakeInstanceService{instances: []*compute.Instance{newTestInstance("id1", "node1")}}
+	cloud := newTestCloud(instanceSvc)
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	metadata, err := cloud.InstanceMetadata(context.Background(), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 1 {
+		t.Errorf("expected a single API call, got %d", instanceSvc.calls)
+	}
+	if metadata.ProviderID != "yandex://id1" {
+		t.Errorf("unexpected ProviderID %q", metadata.ProviderID)
+	}
+	if metadata.Zone != "ru-central1-a" || metadata.Region != "ru-central1" {
+		t.Errorf("unexpected zone %q and region %q", metadata.Zone, metadata.Region)
+	}
+	if len(metadata.NodeAddresses) != 2 {
+		t.Errorf("expected internal and external addresses, got %v", metadata.NodeAddresses)
+	}
+
+	node.Spec.ProviderID = metadata.ProviderID
+	exists, err := cloud.InstanceExists(context.Background(), node)
+	if err != nil || !exists {
+		t.Errorf("instance should exist, got %v, %v", exists, err)
+	}
+
+	shutdown, err := cloud.InstanceShutdown(context.Background(), node)
+	if err != nil || shutdown {
+		t.Errorf("instance should not be shut down, got %v, %v", shutdown, err)
+	}
+
+	for _, missingNode := range []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "missing"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}, Spec: v1.NodeSpec{ProviderID: "yandex://missing"}},
+	} {
+		exists, err = cloud.InstanceExists(context.Background(), missingNode)
+		if err != nil || exists {
+			t.Errorf("instance for %+v should not exist, got %v, %v", missingNode, exists, err)
+		}
+	}
+}
+
+func TestGetInstanceByNodeNameMapping(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.Fqdn = "node1.ru-central1.internal"
+	instance.Labels = map[string]string{"node-name": "k8s-node1"}
+
+	tests := []struct {
+		mapping  nodeNameMapping
+		nodeName string
+	}{
+		{nodeNameMappingVMName, "vm1"},
+		{nodeNameMappingHostname, "node1"},
+		{nodeNameMappingFQDN, "node1.ru-central1.internal"},
+		{nodeNameMappingInstanceLabel, "k8s-node1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.mapping), func(t *testing.T) {
+			yc := newTestCloud(&fakeInstanceService{instances: []*compute.Instance{instance}})
+			yc.config.NodeNameMapping = tc.mapping
+			yc.config.NodeNameInstanceLabel = "node-name"
+
+			found, err := yc.getInstanceByNodeName(context.Background(), types.NodeName(tc.nodeName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.Id != "id1" {
+				t.Errorf("unexpected instance %q", found.Id)
+			}
+
+			if _, err := yc.getInstanceByNodeName(context.Background(), "unknown"); err != cloudprovider.InstanceNotFound {
+				t.Errorf("expected InstanceNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInstanceExistsRecreated(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "vm1"},
+		Spec:       v1.NodeSpec{ProviderID: "yandex://old-id"},
+	}
+
+	for _, oldInstanceFound := range []bool{false, true} {
+		t.Run(fmt.Sprintf("old instance found %t", oldInstanceFound), func(t *testing.T) {
+			instances := []*compute.Instance{newTestInstance("new-id", "vm1")}
+			if oldInstanceFound {
+				// e.g. served from the instance cache
+				instances = append(instances, newTestInstance("old-id", "vm1-old"))
+			}
+			yc := newTestCloud(&fakeInstanceService{instances: instances})
+			recorder := record.NewFakeRecorder(1)
+			yc.recorder = recorder
+
+			// other lookups don't return the replaced instance either, but emit no event
+			if _, err := yc.getInstanceByNode(context.Background(), node); err != cloudprovider.InstanceNotFound {
+				t.Errorf("expected InstanceNotFound, got %v", err)
+			}
+			if len(recorder.Events) > 0 {
+				t.Errorf("unexpected event %q", <-recorder.Events)
+			}
+
+			exists, err := yc.InstanceExists(context.Background(), node)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists {
+				t.Error("recreated instance should be reported as non-existent")
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, "InstanceRecreated") {
+					t.Errorf("unexpected event %q", event)
+				}
+			default:
+				t.Error("expected an InstanceRecreated event")
+			}
+		})
+	}
+}
+
+func TestGetInstanceByNodeRecreationCheck(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "vm1"},
+		Spec:       v1.NodeSpec{ProviderID: "yandex://id1"},
+	}
+
+	instanceSvc := &fakeInstanceService{instances: []*compute.Instance{newTestInstance("id1", "vm1")}}
+	yc := newTestCloud(instanceSvc)
+	exists, err := yc.InstanceExists(context.Background(), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("instance should exist")
+	}
+	// the name lookup is not repeated when the instance ID matches
+	if instanceSvc.calls != 2 {
+		t.Errorf("expected a name and an ID lookup, got %d API calls", instanceSvc.calls)
+	}
+
+	// the Node's name is resolved through its own ProviderID, so the recreation is not looked for
+	instanceSvc = &fakeInstanceService{instances: []*compute.Instance{newTestInstance("id1", "vm1-old"), newTestInstance("id2", "vm1")}}
+	yc = newTestCloud(instanceSvc)
+	yc.config.NodeNameMapping = nodeNameMappingProviderID
+	instance, err := yc.getInstanceByNode(context.Background(), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Id != "id1" || instanceSvc.calls != 1 {
+		t.Errorf("expected instance id1 by a single API call, got %q by %d calls", instance.Id, instanceSvc.calls)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/netip"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -20,12 +23,17 @@ const (
 	externalLoadBalancerAnnotation        = "yandex.cpi.flant.com/loadbalancer-external"
 	listenerSubnetIdAnnotation            = "yandex.cpi.flant.com/listener-subnet-id"
 	listenerAddressIPv4                   = "yandex.cpi.flant.com/listener-address-ipv4"
+	listenerAddressIPv6                   = "yandex.cpi.flant.com/listener-address-ipv6"
 
 	// healthcheck options
 	healthcheckIntervalSeconds    = "yandex.cpi.flant.com/healthcheck-interval-seconds"
 	healthcheckTimeoutSeconds     = "yandex.cpi.flant.com/healthcheck-timeout-seconds"
 	healthcheckUnhealthyThreshold = "yandex.cpi.flant.com/healthcheck-unhealthy-threshold"
 	healthcheckHealthyThreshold   = "yandex.cpi.flant.com/healthcheck-healthy-threshold"
+	healthcheckProtocol           = "yandex.cpi.flant.com/healthcheck-protocol"
+	healthcheckPort               = "yandex.cpi.flant.com/healthcheck-port"
+	healthcheckPath               = "yandex.cpi.flant.com/healthcheck-path"
+	healthcheckHost               = "yandex.cpi.flant.com/healthcheck-host"
 
 	nodesHealthCheckPath = "/healthz"
 	// NOTE: Please keep the following port in sync with ProxyHealthzPort in pkg/cluster/ports/ports.go
@@ -35,32 +43,54 @@ const (
 	lbNodesHealthCheckPort = 10256
 )
 
+// Health check protocols.
+const (
+	healthCheckProtocolHTTP = "HTTP"
+	healthCheckProtocolTCP  = "TCP"
+)
+
 var kubeToYandexServiceProtoMapping = map[v1.Protocol]loadbalancer.Listener_Protocol{
 	v1.ProtocolTCP: loadbalancer.Listener_TCP,
 	v1.ProtocolUDP: loadbalancer.Listener_UDP,
 }
 
+var yandexToKubeServiceProtoMapping = map[loadbalancer.Listener_Protocol]v1.Protocol{
+	loadbalancer.Listener_TCP: v1.ProtocolTCP,
+	loadbalancer.Listener_UDP: v1.ProtocolUDP,
+}
+
 // GetLoadBalancer is an implementation of LoadBalancer.GetLoadBalancer
 func (yc *Cloud) GetLoadBalancer(ctx context.Context, _ string, service *v1.Service) (status *v1.LoadBalancerStatus, exists bool, err error) {
 	lbName := defaultLoadBalancerName(service)
 
+	lbType, err := yc.loadBalancerType(service)
+	if err != nil {
+		return &v1.LoadBalancerStatus{}, false, err
+	}
+	if lbType == loadBalancerTypeALB {
+		log.Printf("Retrieving ALB by name %q", lbName)
+		alb, err := yc.yandexService.AlbSvc.GetALBByName(ctx, lbName)
+		if err != nil || alb == nil {
+			return &v1.LoadBalancerStatus{}, false, err
+		}
+
+		return albLoadBalancerStatus(alb), true, nil
+	}
+
 	log.Printf("Retrieving LB by name %q", lbName)
 	lb, err := yc.yandexService.LbSvc.GetLbByName(ctx, lbName)
 	if err != nil {
 		return &v1.LoadBalancerStatus{}, false, err
 	}
 	if lb == nil {
-		return &v1.LoadBalancerStatus{}, false, nil
-	}
-
-	var lbIngresses []v1.LoadBalancerIngress
-	for _, listener := range lb.Listeners {
-		lbIngresses = append(lbIngresses, v1.LoadBalancerIngress{
-			IP: fmt.Sprintf("%s://%s:%v", strings.ToLower(loadbalancer.Listener_Protocol_name[int32(listener.Protocol)]), listener.Address, listener.Port),
-		})
+		// the old NLB of an interrupted replacement may already be gone
+		lb, err = yc.yandexService.LbSvc.GetLbByName(ctx, replacementLoadBalancerName(lbName))
+		if err != nil || lb == nil {
+			return &v1.LoadBalancerStatus{}, false, err
+		}
 	}
 
-	return &v1.LoadBalancerStatus{Ingress: lbIngresses}, true, nil
+	return loadBalancerStatus(lb), true, nil
 }
 
 // GetLoadBalancerName is an implementation of LoadBalancer.GetLoadBalancerName.
@@ -98,6 +128,29 @@ func (yc *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, _ string, servic
 		return err
 	}
 
+	err = yc.yandexService.LbSvc.RemoveLBByName(ctx, replacementLoadBalancerName(lbName))
+	if err != nil {
+		return err
+	}
+
+	err = yc.removeReplacementTGs(ctx, lbName)
+	if err != nil {
+		return err
+	}
+
+	// the type may have been changed since the load balancer was created, so both implementations are cleaned up
+	if yc.hasALB(service) {
+		err = yc.yandexService.AlbSvc.RemoveALBByName(ctx, lbName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = yc.releaseStaticAddress(ctx, service)
+	if err != nil {
+		return err
+	}
+
 	return yc.nodeTargetGroupSyncer.SyncTGs(ctx, []*v1.Node{})
 }
 
@@ -127,47 +180,205 @@ func (yc *Cloud) ensureLB(ctx context.Context, service *v1.Service, nodes []*v1.
 		return nil, fmt.Errorf("error while extracting parameters: %w", err)
 	}
 
-	var listenerSpecs []*loadbalancer.ListenerSpec
-	for index, svcPort := range service.Spec.Ports {
-		listenerName := svcPort.Name
-		if len(listenerName) == 0 {
-			listenerName = "listener-" + strconv.Itoa(index)
+	lbType, err := yc.loadBalancerType(service)
+	if err != nil {
+		return nil, err
+	}
+	// checked before the load balancer is replaced, so that a misconfigured Service keeps its load balancer
+	if lbType != loadBalancerTypeALB && len(lbParams.healthcheckHost) > 0 {
+		return nil, fmt.Errorf("the health check Host is only supported by Application Load Balancers")
+	}
+	if lbType == loadBalancerTypeALB {
+		if lb, err := yc.yandexService.LbSvc.GetLbByName(ctx, lbName); err != nil {
+			return nil, err
+		} else if lb != nil {
+			if err := yc.guardDisruption(service, fmt.Sprintf("replace NLB %q with an ALB", lbName)); err != nil {
+				return nil, err
+			}
+			if err := yc.yandexService.LbSvc.RemoveLBByName(ctx, lbName); err != nil {
+				return nil, err
+			}
+		}
+		return yc.ensureALB(ctx, service, lbParams)
+	}
+	if yc.hasALB(service) {
+		if alb, err := yc.yandexService.AlbSvc.GetALBByName(ctx, lbName); err != nil {
+			return nil, err
+		} else if alb != nil {
+			if err := yc.guardDisruption(service, fmt.Sprintf("replace ALB %q with an NLB", lbName)); err != nil {
+				return nil, err
+			}
+		}
+		if err := yc.yandexService.AlbSvc.RemoveALBByName(ctx, lbName); err != nil {
+			return nil, err
 		}
+		if err := yc.setALBProvisioned(ctx, service, false); err != nil {
+			return nil, err
+		}
+	}
 
-		listenerSpec := &loadbalancer.ListenerSpec{
-			Name:       listenerName,
-			Port:       int64(svcPort.Port),
-			Protocol:   kubeToYandexServiceProtoMapping[svcPort.Protocol],
-			TargetPort: int64(svcPort.NodePort),
+	// pinned addresses are managed by the user
+	if lbParams.staticAddress && !lbParams.internal && len(lbParams.listenerAddressIPv4) == 0 &&
+		slices.Contains(serviceIPFamilies(service), v1.IPv4Protocol) {
+		lbParams.listenerAddressIPv4, err = yc.ensureStaticAddress(ctx, service, lbName)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if lbParams.internal {
-			internalAddressSpec := &loadbalancer.InternalAddressSpec{
-				SubnetId: lbParams.listenerSubnetID,
-			}
+	listenerSpecs, err := buildListenerSpecs(service, lbParams)
+	if err != nil {
+		return nil, err
+	}
 
-			if len(lbParams.listenerAddressIPv4) > 0 {
-				internalAddressSpec.Address = lbParams.listenerAddressIPv4
-				internalAddressSpec.IpVersion = loadbalancer.IpVersion_IPV4
-			}
+	healthCheck := buildHealthCheck(service, lbParams)
+	healthChecks := []*loadbalancer.HealthCheck{healthCheck}
+
+	tgName := lbParams.targetGroupNamePrefix + yc.config.ClusterName + lbParams.targetGroupNetworkID
+
+	tg, err := yc.yandexService.LbSvc.GetTgByName(ctx, tgName)
+	if err != nil {
+		return nil, err
+	}
+	if tg == nil {
+		return nil, fmt.Errorf("TG %q does not exist yet", tgName)
+	}
 
-			listenerSpec.Address = &loadbalancer.ListenerSpec_InternalAddressSpec{
-				InternalAddressSpec: internalAddressSpec,
+	lb, err := yc.ensureNLB(ctx, service, lbName, lbParams.labels, listenerSpecs, []*loadbalancer.AttachedTargetGroup{
+		{
+			TargetGroupId: tg.Id,
+			HealthChecks:  healthChecks,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loadBalancerStatus(lb), nil
+}
+
+// buildListenerSpecs builds a listener for every port of the Service in every IP family of the Service.
+// IPv4 listeners are named after the ports, IPv6 listeners get the "-ipv6" suffix.
+func buildListenerSpecs(service *v1.Service, lbParams loadBalancerParameters) ([]*loadbalancer.ListenerSpec, error) {
+	ipFamilies := serviceIPFamilies(service)
+	if len(lbParams.listenerAddressIPv6) > 0 && !slices.Contains(ipFamilies, v1.IPv6Protocol) {
+		return nil, fmt.Errorf("annotation %q is set, but the Service has no IPv6 family", listenerAddressIPv6)
+	}
+	if len(service.Spec.Ports)*len(ipFamilies) > 10 {
+		return nil, fmt.Errorf("Yandex.Cloud API does not support more than 10 listeners, the Service needs %d", len(service.Spec.Ports)*len(ipFamilies))
+	}
+
+	var listenerSpecs []*loadbalancer.ListenerSpec
+	for _, ipFamily := range ipFamilies {
+		ipVersion, address, nameSuffix := loadbalancer.IpVersion_IPV4, lbParams.listenerAddressIPv4, ""
+		switch ipFamily {
+		case v1.IPv4Protocol:
+		case v1.IPv6Protocol:
+			ipVersion, address, nameSuffix = loadbalancer.IpVersion_IPV6, lbParams.listenerAddressIPv6, "-ipv6"
+		default:
+			return nil, fmt.Errorf("unsupported IP family %q", ipFamily)
+		}
+
+		for index, svcPort := range service.Spec.Ports {
+			listenerName := svcPort.Name
+			if len(listenerName) == 0 {
+				listenerName = "listener-" + strconv.Itoa(index)
 			}
-		} else {
-			externalAddressSpec := &loadbalancer.ExternalAddressSpec{}
 
-			if len(lbParams.listenerAddressIPv4) > 0 {
-				externalAddressSpec.Address = lbParams.listenerAddressIPv4
-				externalAddressSpec.IpVersion = loadbalancer.IpVersion_IPV4
+			listenerSpec := &loadbalancer.ListenerSpec{
+				Name:       listenerName + nameSuffix,
+				Port:       int64(svcPort.Port),
+				Protocol:   kubeToYandexServiceProtoMapping[svcPort.Protocol],
+				TargetPort: int64(svcPort.NodePort),
 			}
 
-			listenerSpec.Address = &loadbalancer.ListenerSpec_ExternalAddressSpec{
-				ExternalAddressSpec: externalAddressSpec,
+			if lbParams.internal {
+				listenerSpec.Address = &loadbalancer.ListenerSpec_InternalAddressSpec{
+					InternalAddressSpec: &loadbalancer.InternalAddressSpec{
+						SubnetId:  lbParams.listenerSubnetID,
+						Address:   address,
+						IpVersion: ipVersion,
+					},
+				}
+			} else {
+				listenerSpec.Address = &loadbalancer.ListenerSpec_ExternalAddressSpec{
+					ExternalAddressSpec: &loadbalancer.ExternalAddressSpec{
+						Address:   address,
+						IpVersion: ipVersion,
+					},
+				}
 			}
+
+			listenerSpecs = append(listenerSpecs, listenerSpec)
+		}
+	}
+
+	return listenerSpecs, nil
+}
+
+func serviceIPFamilies(service *v1.Service) []v1.IPFamily {
+	if len(service.Spec.IPFamilies) == 0 {
+		return []v1.IPFamily{v1.IPv4Protocol}
+	}
+	return service.Spec.IPFamilies
+}
+
+// loadBalancerStatus builds the Service status out of the NLB listeners:
+// one ingress point per distinct listener address with all ports served on it.
+func loadBalancerStatus(lb *loadbalancer.NetworkLoadBalancer) *v1.LoadBalancerStatus {
+	var ingresses []v1.LoadBalancerIngress
+	ingressByAddress := make(map[netip.Addr]int)
+
+	for _, listener := range lb.GetListeners() {
+		addr, err := netip.ParseAddr(listener.Address)
+		if err != nil {
+			log.Printf("Skipping listener %q of LB %q with malformed address %q", listener.Name, lb.Name, listener.Address)
+			continue
+		}
+		addr = addr.Unmap()
+
+		index, ok := ingressByAddress[addr]
+		if !ok {
+			ipMode := v1.LoadBalancerIPModeVIP
+			ingresses = append(ingresses, v1.LoadBalancerIngress{IP: addr.String(), IPMode: &ipMode})
+			index = len(ingresses) - 1
+			ingressByAddress[addr] = index
 		}
 
-		listenerSpecs = append(listenerSpecs, listenerSpec)
+		ingresses[index].Ports = append(ingresses[index].Ports, v1.PortStatus{
+			Port:     int32(listener.Port),
+			Protocol: yandexToKubeServiceProtoMapping[listener.Protocol],
+		})
+	}
+
+	sortLoadBalancerIngresses(ingresses)
+
+	return &v1.LoadBalancerStatus{Ingress: ingresses}
+}
+
+// sortLoadBalancerIngresses keeps the status stable regardless of the order the listeners are returned in.
+func sortLoadBalancerIngresses(ingresses []v1.LoadBalancerIngress) {
+	sort.Slice(ingresses, func(i, j int) bool {
+		a, b := netip.MustParseAddr(ingresses[i].IP), netip.MustParseAddr(ingresses[j].IP)
+		return a.Less(b)
+	})
+	for _, ingress := range ingresses {
+		sort.Slice(ingress.Ports, func(i, j int) bool {
+			if ingress.Ports[i].Port != ingress.Ports[j].Port {
+				return ingress.Ports[i].Port < ingress.Ports[j].Port
+			}
+			return ingress.Ports[i].Protocol < ingress.Ports[j].Protocol
+		})
+	}
+}
+
+// buildHealthCheck builds the health check of the Nodes behind the load balancer of the Service.
+// By default, kube-proxy healthz is checked, the configured port, path and protocol take precedence.
+func buildHealthCheck(service *v1.Service, lbParams loadBalancerParameters) *loadbalancer.HealthCheck {
+	if lbParams.healthcheckProtocol == healthCheckProtocolTCP && svchelpers.RequestsOnlyLocalTraffic(service) {
+		// a TCP check passes on Nodes without local endpoints, only kube-proxy knows where the traffic is served
+		log.Printf("Service %s/%s has externalTrafficPolicy Local, ignoring the TCP health check", service.Namespace, service.Name)
+		lbParams.healthcheckProtocol, lbParams.healthcheckPort = "", 0
 	}
 
 	hcPath, hcPort := nodesHealthCheckPath, int32(lbNodesHealthCheckPort)
@@ -175,6 +386,12 @@ func (yc *Cloud) ensureLB(ctx context.Context, service *v1.Service, nodes []*v1.
 		// Service requires a special health check, retrieve the OnlyLocal port & path
 		hcPath, hcPort = svchelpers.GetServiceHealthCheckPathPort(service)
 	}
+	if lbParams.healthcheckPort > 0 {
+		hcPort = int32(lbParams.healthcheckPort)
+	}
+	if len(lbParams.healthcheckPath) > 0 {
+		hcPath = lbParams.healthcheckPath
+	}
 
 	healthCheck := &loadbalancer.HealthCheck{
 		Name:               "kube-health-check",
@@ -182,12 +399,21 @@ func (yc *Cloud) ensureLB(ctx context.Context, service *v1.Service, nodes []*v1.
 		Timeout:            &durationpb.Duration{Seconds: 1},
 		UnhealthyThreshold: 2,
 		HealthyThreshold:   2,
-		Options: &loadbalancer.HealthCheck_HttpOptions_{
+	}
+
+	if lbParams.healthcheckProtocol == healthCheckProtocolTCP {
+		healthCheck.Options = &loadbalancer.HealthCheck_TcpOptions_{
+			TcpOptions: &loadbalancer.HealthCheck_TcpOptions{
+				Port: int64(hcPort),
+			},
+		}
+	} else {
+		healthCheck.Options = &loadbalancer.HealthCheck_HttpOptions_{
 			HttpOptions: &loadbalancer.HealthCheck_HttpOptions{
 				Port: int64(hcPort),
 				Path: hcPath,
 			},
-		},
+		}
 	}
 
 	if lbParams.healthcheckIntervalSeconds > 0 {
@@ -206,6 +432,17 @@ func (yc *Cloud) ensureLB(ctx context.Context, service *v1.Service, nodes []*v1.
 		healthCheck.HealthyThreshold = int64(lbParams.healthcheckHealthyThreshold)
 	}
 
+	if tcpOptions := healthCheck.GetTcpOptions(); tcpOptions != nil {
+		log.Printf("Health checking TCP port %v; interval %v, timeout %v, UnhealthyThreshold %d, HealthyThreshold %d",
+			tcpOptions.Port,
+			healthCheck.GetInterval(),
+			healthCheck.GetTimeout(),
+			healthCheck.GetUnhealthyThreshold(),
+			healthCheck.GetHealthyThreshold(),
+		)
+		return healthCheck
+	}
+
 	log.Printf("Health checking on path %q and port %v; interval %v, timeout %v, UnhealthyThreshold %d, HealthyThreshold %d",
 		healthCheck.GetHttpOptions().Path,
 		healthCheck.GetHttpOptions().Port,
@@ -214,29 +451,8 @@ func (yc *Cloud) ensureLB(ctx context.Context, service *v1.Service, nodes []*v1.
 		healthCheck.GetUnhealthyThreshold(),
 		healthCheck.GetHealthyThreshold(),
 	)
-	healthChecks := []*loadbalancer.HealthCheck{healthCheck}
 
-	tgName := lbParams.targetGroupNamePrefix + yc.config.ClusterName + lbParams.targetGroupNetworkID
-
-	tg, err := yc.yandexService.LbSvc.GetTgByName(ctx, tgName)
-	if err != nil {
-		return nil, err
-	}
-	if tg == nil {
-		return nil, fmt.Errorf("TG %q does not exist yet", tgName)
-	}
-
-	externalIP, err := yc.yandexService.LbSvc.CreateOrUpdateLB(ctx, lbName, listenerSpecs, []*loadbalancer.AttachedTargetGroup{
-		{
-			TargetGroupId: tg.Id,
-			HealthChecks:  healthChecks,
-		},
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: externalIP}}}, nil
+	return healthCheck
 }
 
 type loadBalancerParameters struct {
@@ -244,18 +460,44 @@ type loadBalancerParameters struct {
 	targetGroupNamePrefix string
 	listenerSubnetID      string
 	listenerAddressIPv4   string
+	listenerAddressIPv6   string
 	internal              bool
+	// staticAddress reserves the external IPv4 address unless it is pinned
+	staticAddress bool
+	// labels are managed on the load balancer only when set
+	labels map[string]string
 
 	healthcheckIntervalSeconds    int
 	healthcheckTimeoutSeconds     int
 	healthcheckUnhealthyThreshold int
 	healthcheckHealthyThreshold   int
+	// healthcheckProtocol is HTTP or TCP, an empty value means HTTP
+	healthcheckProtocol string
+	healthcheckPort     int
+	healthcheckPath     string
+	healthcheckHost     string
 }
 
 func (yc *Cloud) getLoadBalancerParameters(svc *v1.Service) (lbParams loadBalancerParameters, err error) {
+	class, ok := yc.loadBalancerClassOf(svc)
+	if !ok {
+		return lbParams, fmt.Errorf("load balancer class %q is not handled by this controller", *svc.Spec.LoadBalancerClass)
+	}
+
 	if value, ok := svc.Annotations[listenerSubnetIdAnnotation]; ok {
 		lbParams.internal = true
 		lbParams.listenerSubnetID = value
+	} else if class != nil {
+		lbParams.internal = class.Internal
+		if lbParams.internal {
+			lbParams.listenerSubnetID = class.ListenerSubnetID
+			if len(lbParams.listenerSubnetID) == 0 {
+				lbParams.listenerSubnetID = yc.config.lbListenerSubnetID
+			}
+			if len(lbParams.listenerSubnetID) == 0 {
+				return lbParams, fmt.Errorf("load balancer class %q is internal, but no listener subnet is configured", class.Name)
+			}
+		}
 	} else if len(yc.config.lbListenerSubnetID) != 0 {
 		lbParams.listenerSubnetID = yc.config.lbListenerSubnetID
 		_, isExternal := svc.Annotations[externalLoadBalancerAnnotation]
@@ -264,14 +506,40 @@ func (yc *Cloud) getLoadBalancerParameters(svc *v1.Service) (lbParams loadBalanc
 
 	if value, ok := svc.Annotations[targetGroupNetworkIdAnnotation]; ok {
 		lbParams.targetGroupNetworkID = value
+	} else if class != nil && len(class.TargetGroupNetworkID) != 0 {
+		lbParams.targetGroupNetworkID = class.TargetGroupNetworkID
 	} else if len(yc.config.lbTgNetworkID) != 0 {
 		lbParams.targetGroupNetworkID = yc.config.lbTgNetworkID
 	}
 
+	if class != nil {
+		lbParams.healthcheckIntervalSeconds = class.HealthCheck.IntervalSeconds
+		lbParams.healthcheckTimeoutSeconds = class.HealthCheck.TimeoutSeconds
+		lbParams.healthcheckUnhealthyThreshold = class.HealthCheck.UnhealthyThreshold
+		lbParams.healthcheckHealthyThreshold = class.HealthCheck.HealthyThreshold
+		lbParams.healthcheckProtocol = class.HealthCheck.Protocol
+		lbParams.healthcheckPort = class.HealthCheck.Port
+		lbParams.healthcheckPath = class.HealthCheck.Path
+		lbParams.healthcheckHost = class.HealthCheck.Host
+		lbParams.labels = class.Labels
+	}
+
 	if value, ok := svc.Annotations[listenerAddressIPv4]; ok {
 		lbParams.listenerAddressIPv4 = value
 	}
 
+	if value, ok := svc.Annotations[listenerAddressIPv6]; ok {
+		if addr, err := netip.ParseAddr(value); err != nil || !addr.Is6() {
+			return lbParams, fmt.Errorf("value of annotation %q is not an IPv6 address: %q", listenerAddressIPv6, value)
+		}
+		lbParams.listenerAddressIPv6 = value
+	}
+
+	lbParams.staticAddress, err = yc.reservesStaticAddress(svc)
+	if err != nil {
+		return
+	}
+
 	if value, ok := svc.Annotations[customTargetGroupNamePrefixAnnotation]; ok {
 		lbParams.targetGroupNamePrefix = value
 	}
@@ -303,9 +571,52 @@ func (yc *Cloud) getLoadBalancerParameters(svc *v1.Service) (lbParams loadBalanc
 			return
 		}
 	}
+
+	if value, ok := svc.Annotations[healthcheckProtocol]; ok {
+		lbParams.healthcheckProtocol = strings.ToUpper(value)
+	}
+
+	if value, ok := svc.Annotations[healthcheckPort]; ok {
+		lbParams.healthcheckPort, err = tryAnnotationValueToInt(healthcheckPort, value)
+		if err != nil {
+			return
+		}
+	}
+
+	if value, ok := svc.Annotations[healthcheckPath]; ok {
+		lbParams.healthcheckPath = value
+	}
+
+	if value, ok := svc.Annotations[healthcheckHost]; ok {
+		lbParams.healthcheckHost = value
+	}
+
+	err = validateHealthCheckOptions(lbParams.healthcheckProtocol, lbParams.healthcheckPort, lbParams.healthcheckPath, lbParams.healthcheckHost)
 	return
 }
 
+// validateHealthCheckOptions checks the health check protocol options, the path and the Host only make sense for HTTP.
+func validateHealthCheckOptions(protocol string, port int, path, host string) error {
+	switch protocol {
+	case "", healthCheckProtocolHTTP:
+	case healthCheckProtocolTCP:
+		if len(path) > 0 || len(host) > 0 {
+			return fmt.Errorf("the health check path and Host can't be set for TCP health checks")
+		}
+	default:
+		return fmt.Errorf("unsupported health check protocol %q, expected %q or %q", protocol, healthCheckProtocolHTTP, healthCheckProtocolTCP)
+	}
+
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("invalid health check port %d", port)
+	}
+	if len(path) > 0 && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("health check path %q must start with \"/\"", path)
+	}
+
+	return nil
+}
+
 func tryAnnotationValueToInt(name, value string) (int, error) {
 	v, err := strconv.Atoi(value)
 	if err != nil {
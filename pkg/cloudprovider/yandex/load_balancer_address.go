@@ -0,0 +1,119 @@
+package yandex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// staticAddressAnnotation enables or disables the static address reservation for the Service,
+	// overriding reserveStaticAddresses of the cloud config
+	staticAddressAnnotation = "yandex.cpi.flant.com/static-address"
+	// staticAddressPolicyAnnotation tells whether the reserved address is kept ("Retain") or released ("Release", default)
+	// when the load balancer of the Service is deleted
+	staticAddressPolicyAnnotation = "yandex.cpi.flant.com/static-address-policy"
+
+	staticAddressPolicyRetain  = "Retain"
+	staticAddressPolicyRelease = "Release"
+
+	// labels of the reserved addresses
+	staticAddressClusterLabel   = "kubernetes-cluster"
+	staticAddressNamespaceLabel = "kubernetes-namespace"
+	staticAddressServiceLabel   = "kubernetes-service"
+)
+
+// staticAddressName is derived from the Service namespace and name rather than the UID,
+// so that a retained address is picked up again by a re-created Service.
+func (yc *Cloud) staticAddressName(service *v1.Service) string {
+	sum := sha256.Sum256([]byte(yc.config.ClusterName + "/" + service.Namespace + "/" + service.Name))
+	return "a" + hex.EncodeToString(sum[:])[:31]
+}
+
+func (yc *Cloud) staticAddressLabels(service *v1.Service) map[string]string {
+	return map[string]string{
+		staticAddressClusterLabel:   strings.ToLower(yc.config.ClusterName),
+		staticAddressNamespaceLabel: service.Namespace,
+		staticAddressServiceLabel:   service.Name,
+	}
+}
+
+// reservesStaticAddress tells whether the external IPv4 address of the Service load balancer must be reserved.
+func (yc *Cloud) reservesStaticAddress(service *v1.Service) (bool, error) {
+	reserve := yc.config.ReserveStaticAddresses
+	if value, ok := service.Annotations[staticAddressAnnotation]; ok {
+		var err error
+		reserve, err = strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("can't parse value of annotation %q: %q, error %w", staticAddressAnnotation, value, err)
+		}
+	}
+
+	if value, ok := service.Annotations[staticAddressPolicyAnnotation]; ok &&
+		!strings.EqualFold(value, staticAddressPolicyRetain) && !strings.EqualFold(value, staticAddressPolicyRelease) {
+		return false, fmt.Errorf("unsupported value of annotation %q: %q, expected %q or %q",
+			staticAddressPolicyAnnotation, value, staticAddressPolicyRetain, staticAddressPolicyRelease)
+	}
+
+	return reserve, nil
+}
+
+// ensureStaticAddress returns the reserved external IPv4 address of the Service load balancer.
+// The ephemeral address of an existing load balancer is reserved, so that enabling the reservation keeps the IP.
+func (yc *Cloud) ensureStaticAddress(ctx context.Context, service *v1.Service, lbName string) (string, error) {
+	name := yc.staticAddressName(service)
+
+	address, err := yc.yandexService.VPCSvc.GetAddressByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if address == nil {
+		lb, err := yc.yandexService.LbSvc.GetLbByName(ctx, lbName)
+		if err != nil {
+			return "", err
+		}
+
+		var currentAddress string
+		if lb.GetType() == loadbalancer.NetworkLoadBalancer_EXTERNAL {
+			for _, listener := range lb.GetListeners() {
+				if addr, err := netip.ParseAddr(listener.Address); err == nil && addr.Is4() {
+					currentAddress = listener.Address
+					break
+				}
+			}
+		}
+
+		address, err = yc.yandexService.VPCSvc.ReserveExternalAddress(ctx, name, yc.staticAddressLabels(service), yc.config.LocalZone, currentAddress)
+		if err != nil {
+			return "", fmt.Errorf("failed to reserve an address for LB %q: %s", lbName, err)
+		}
+	}
+
+	value := address.GetExternalIpv4Address().GetAddress()
+	if len(value) == 0 {
+		return "", fmt.Errorf("address %q is not an external IPv4 address", name)
+	}
+
+	return value, nil
+}
+
+// releaseStaticAddress releases the reserved address of the Service unless the Service asks to retain it.
+// The address is released even if the Service no longer reserves addresses, but only if it is labeled with the Service.
+func (yc *Cloud) releaseStaticAddress(ctx context.Context, service *v1.Service) error {
+	name := yc.staticAddressName(service)
+	if strings.EqualFold(service.Annotations[staticAddressPolicyAnnotation], staticAddressPolicyRetain) {
+		log.Printf("Retaining Address %q of Service %s/%s", name, service.Namespace, service.Name)
+		return nil
+	}
+
+	return yc.yandexService.VPCSvc.RemoveAddressByName(ctx, name, yc.staticAddressLabels(service))
+}
@@ -0,0 +1,178 @@
+package yandex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	//nolint:staticcheck // Ignore SA1019. Need to keep deprecated package for compatibility.
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	ycsdkoperation "github.com/yandex-cloud/go-sdk/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+// fakeAddressService keeps addresses in memory, last is the address changed by the last operation.
+type fakeAddressService struct {
+	vpc.AddressServiceClient
+
+	addresses []*vpc.Address
+	last      *vpc.Address
+}
+
+func (f *fakeAddressService) List(_ context.Context, in *vpc.ListAddressesRequest, _ ...grpc.CallOption) (*vpc.ListAddressesResponse, error) {
+	var ret []*vpc.Address
+	for _, address := range f.addresses {
+		if in.Filter == `name = "`+address.Name+`"` {
+			ret = append(ret, address)
+		}
+	}
+
+	return &vpc.ListAddressesResponse{Addresses: ret}, nil
+}
+
+func (f *fakeAddressService) GetByValue(_ context.Context, in *vpc.GetAddressByValueRequest, _ ...grpc.CallOption) (*vpc.Address, error) {
+	for _, address := range f.addresses {
+		if address.GetExternalIpv4Address().GetAddress() == in.GetExternalIpv4Address() {
+			return address, nil
+		}
+	}
+
+	return nil, status.Error(codes.NotFound, "not found")
+}
+
+func (f *fakeAddressService) Create(_ context.Context, in *vpc.CreateAddressRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	f.last = &vpc.Address{
+		Id:       "new",
+		Name:     in.Name,
+		Labels:   in.Labels,
+		Reserved: true,
+		Address:  &vpc.Address_ExternalIpv4Address{ExternalIpv4Address: &vpc.ExternalIpv4Address{Address: "5.6.7.8"}},
+	}
+	f.addresses = append(f.addresses, f.last)
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeAddressService) Update(_ context.Context, in *vpc.UpdateAddressRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, address := range f.addresses {
+		if address.Id == in.AddressId {
+			address.Name, address.Labels, address.Reserved = in.Name, in.Labels, in.Reserved
+			f.last = address
+		}
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeAddressService) Delete(_ context.Context, in *vpc.DeleteAddressRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	var addresses []*vpc.Address
+	for _, address := range f.addresses {
+		if address.Id != in.AddressId {
+			addresses = append(addresses, address)
+		}
+	}
+	f.addresses = addresses
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func TestStaticAddress(t *testing.T) {
+	addressSvc := &fakeAddressService{addresses: []*vpc.Address{{
+		Id:      "ephemeral",
+		Address: &vpc.Address_ExternalIpv4Address{ExternalIpv4Address: &vpc.ExternalIpv4Address{Address: "1.2.3.4"}},
+	}}}
+	lbSvc := &fakeNetworkLoadBalancerService{}
+
+	cloudCtx := &yapi.CloudContext{
+		FolderID: "folder",
+		OperationWaiter: func(_ context.Context, origFunc func() (*operation.Operation, error)) (proto.Message, *ycsdkoperation.Operation, error) {
+			_, err := origFunc()
+			return addressSvc.last, nil, err
+		},
+	}
+
+	yc := newTestCloud(nil)
+	yc.config.ClusterName = "Cluster"
+	yc.config.ReserveStaticAddresses = true
+	yc.yandexService.VPCSvc = yapi.NewVPCService(nil, nil, nil, addressSvc, cloudCtx)
+	yc.yandexService.LbSvc = yapi.NewLoadBalancerService(lbSvc, nil, cloudCtx)
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "api", UID: "uid1"}}
+	lbName := defaultLoadBalancerName(service)
+
+	// the ephemeral address of the existing load balancer is reserved
+	lbSvc.lbs = []*loadbalancer.NetworkLoadBalancer{{
+		Name:      lbName,
+		Type:      loadbalancer.NetworkLoadBalancer_EXTERNAL,
+		Listeners: []*loadbalancer.Listener{{Name: "http", Address: "1.2.3.4", Port: 80}},
+	}}
+	address, err := yc.ensureStaticAddress(context.Background(), service, lbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "1.2.3.4" {
+		t.Errorf("ephemeral address should be reserved, got %q", address)
+	}
+	reserved := addressSvc.addresses[0]
+	if !reserved.Reserved || reserved.Name != yc.staticAddressName(service) || reserved.Labels[staticAddressClusterLabel] != "cluster" ||
+		reserved.Labels[staticAddressNamespaceLabel] != "ns" || reserved.Labels[staticAddressServiceLabel] != "api" {
+		t.Errorf("unexpected reserved address %+v", reserved)
+	}
+
+	// the address is retained and picked up again by the re-created Service
+	service.Annotations = map[string]string{staticAddressPolicyAnnotation: "retain"}
+	if err := yc.releaseStaticAddress(context.Background(), service); err != nil {
+		t.Fatal(err)
+	}
+	recreated := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "api", UID: "uid2"}}
+	lbSvc.lbs = nil
+	address, err = yc.ensureStaticAddress(context.Background(), recreated, defaultLoadBalancerName(recreated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "1.2.3.4" {
+		t.Errorf("retained address should be reused, got %q", address)
+	}
+
+	// the address is not released when it is labeled with another Service
+	addressSvc.addresses[0].Labels = map[string]string{staticAddressServiceLabel: "other"}
+	if err := yc.releaseStaticAddress(context.Background(), recreated); err != nil {
+		t.Fatal(err)
+	}
+	if len(addressSvc.addresses) != 1 {
+		t.Errorf("address should be kept, got %+v", addressSvc.addresses)
+	}
+	addressSvc.addresses[0].Labels = yc.staticAddressLabels(recreated)
+
+	// the address is released by default, even if the Service has stopped reserving addresses before being deleted
+	recreated.Annotations = map[string]string{staticAddressAnnotation: "false"}
+	if err := yc.releaseStaticAddress(context.Background(), recreated); err != nil {
+		t.Fatal(err)
+	}
+	if len(addressSvc.addresses) != 0 {
+		t.Errorf("address should be released, got %+v", addressSvc.addresses)
+	}
+	recreated.Annotations = nil
+
+	// a new address is reserved if there is no load balancer
+	address, err = yc.ensureStaticAddress(context.Background(), recreated, defaultLoadBalancerName(recreated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "5.6.7.8" {
+		t.Errorf("new address should be reserved, got %q", address)
+	}
+
+	service.Annotations = map[string]string{staticAddressPolicyAnnotation: "keep"}
+	if _, err := yc.reservesStaticAddress(service); err == nil {
+		t.Error("should return non-nil err for an unsupported policy")
+	}
+}
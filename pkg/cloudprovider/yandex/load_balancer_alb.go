@@ -0,0 +1,330 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/apploadbalancer/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+const (
+	// loadBalancerTypeAnnotation selects the load balancer implementation: "nlb" or "alb"
+	loadBalancerTypeAnnotation = "yandex.cloud/load-balancer-type"
+
+	// ALB options
+	albCertificateIDsAnnotation = "yandex.cloud/alb-certificate-ids"
+	albHTTPSPortsAnnotation     = "yandex.cloud/alb-https-ports"
+	albSubnetIDsAnnotation      = "yandex.cloud/alb-subnet-ids"
+
+	// albProvisionedAnnotation is set by the CCM on Services it has created an ALB for,
+	// the ALB API is only used for such Services and the ones of the ALB type
+	albProvisionedAnnotation = "yandex.cloud/alb-provisioned"
+
+	defaultALBHTTPSPort = 443
+)
+
+// ensureALB creates or updates the Application Load Balancer of the Service.
+// Each Service port gets an HTTP or HTTPS listener routing all requests to the node port through a backend group,
+// the targets are the Nodes of the target group synchronized for network load balancers.
+func (yc *Cloud) ensureALB(ctx context.Context, service *v1.Service, lbParams loadBalancerParameters) (*v1.LoadBalancerStatus, error) {
+	lbName := defaultLoadBalancerName(service)
+
+	listeners, err := albListeners(service)
+	if err != nil {
+		return nil, err
+	}
+
+	tgName := lbParams.targetGroupNamePrefix + yc.config.ClusterName + lbParams.targetGroupNetworkID
+	tg, err := yc.yandexService.LbSvc.GetTgByName(ctx, tgName)
+	if err != nil {
+		return nil, err
+	}
+	if tg == nil {
+		return nil, fmt.Errorf("TG %q does not exist yet", tgName)
+	}
+
+	locations, err := yc.albLocations(ctx, service, lbParams.targetGroupNetworkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yc.guardALBListenerRemoval(ctx, service, lbName, listeners); err != nil {
+		return nil, err
+	}
+
+	if err := yc.setALBProvisioned(ctx, service, true); err != nil {
+		return nil, err
+	}
+
+	alb, err := yc.yandexService.AlbSvc.CreateOrUpdateALB(ctx, &yapi.ALBSpec{
+		Name:        lbName,
+		Labels:      lbParams.labels,
+		NetworkID:   lbParams.targetGroupNetworkID,
+		Locations:   locations,
+		Internal:    lbParams.internal,
+		SubnetID:    lbParams.listenerSubnetID,
+		Address:     lbParams.listenerAddressIPv4,
+		Targets:     albTargets(tg.Targets),
+		HealthCheck: albHealthCheck(buildHealthCheck(service, lbParams), lbParams.healthcheckHost),
+		Listeners:   listeners,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return albLoadBalancerStatus(alb), nil
+}
+
+// albTargets converts the targets of a network load balancer target group to ALB targets.
+func albTargets(targets []*loadbalancer.Target) []*apploadbalancer.Target {
+	var ret []*apploadbalancer.Target
+	for _, target := range targets {
+		// the ALB listeners are IPv4 only, IPv6 targets are there for dual-stack network load balancers
+		if addr, err := netip.ParseAddr(target.Address); err != nil || !addr.Is4() {
+			continue
+		}
+		ret = append(ret, &apploadbalancer.Target{
+			SubnetId:    target.SubnetId,
+			AddressType: &apploadbalancer.Target_IpAddress{IpAddress: target.Address},
+		})
+	}
+
+	return ret
+}
+
+// hasALB tells whether the Service may have an ALB: it is of the ALB type or the CCM has created one for it.
+func (yc *Cloud) hasALB(service *v1.Service) bool {
+	if lbType, err := yc.loadBalancerType(service); err == nil && lbType == loadBalancerTypeALB {
+		return true
+	}
+
+	_, ok := service.Annotations[albProvisionedAnnotation]
+	return ok
+}
+
+// setALBProvisioned records on the Service whether the CCM has created an ALB for it.
+func (yc *Cloud) setALBProvisioned(ctx context.Context, service *v1.Service, present bool) error {
+	if _, ok := service.Annotations[albProvisionedAnnotation]; ok == present || yc.client == nil {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := yc.client.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := current.Annotations[albProvisionedAnnotation]; ok == present {
+			return nil
+		}
+
+		updated := current.DeepCopy()
+		if present {
+			if updated.Annotations == nil {
+				updated.Annotations = make(map[string]string)
+			}
+			updated.Annotations[albProvisionedAnnotation] = "true"
+		} else {
+			delete(updated.Annotations, albProvisionedAnnotation)
+		}
+
+		_, err = yc.client.CoreV1().Services(service.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (yc *Cloud) guardALBListenerRemoval(ctx context.Context, service *v1.Service, lbName string, listeners []yapi.ALBListener) error {
+	alb, err := yc.yandexService.AlbSvc.GetALBByName(ctx, lbName)
+	if err != nil || alb == nil {
+		return err
+	}
+
+	desired := sets.New[string]()
+	for _, listener := range listeners {
+		desired.Insert(listener.Name)
+	}
+	var removed []string
+	for _, listener := range alb.Listeners {
+		if !desired.Has(listener.Name) {
+			removed = append(removed, listener.Name)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	return yc.guardDisruption(service, fmt.Sprintf("remove listeners %s of ALB %q", strings.Join(removed, ", "), lbName))
+}
+
+func albListeners(service *v1.Service) ([]yapi.ALBListener, error) {
+	httpsPorts := sets.New[int32](defaultALBHTTPSPort)
+	if value, ok := service.Annotations[albHTTPSPortsAnnotation]; ok {
+		httpsPorts = sets.New[int32]()
+		for _, port := range strings.Split(value, ",") {
+			if port = strings.TrimSpace(port); len(port) == 0 {
+				continue
+			}
+			parsed, err := strconv.ParseInt(port, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("can't parse value of annotation %q: %q, error %w", albHTTPSPortsAnnotation, value, err)
+			}
+			httpsPorts.Insert(int32(parsed))
+		}
+	}
+
+	var certificateIDs []string
+	for _, id := range strings.Split(service.Annotations[albCertificateIDsAnnotation], ",") {
+		if id = strings.TrimSpace(id); len(id) > 0 {
+			certificateIDs = append(certificateIDs, id)
+		}
+	}
+
+	var listeners []yapi.ALBListener
+	for index, svcPort := range service.Spec.Ports {
+		if svcPort.Protocol != v1.ProtocolTCP {
+			return nil, fmt.Errorf("Application Load Balancers only support TCP ports, port %d is %s", svcPort.Port, svcPort.Protocol)
+		}
+
+		listener := yapi.ALBListener{
+			Name:        svcPort.Name,
+			Port:        int64(svcPort.Port),
+			BackendPort: int64(svcPort.NodePort),
+		}
+		if len(listener.Name) == 0 {
+			listener.Name = "listener-" + strconv.Itoa(index)
+		} else if listener.Name[0] >= '0' && listener.Name[0] <= '9' {
+			// port names may start with a digit, ALB names must start with a letter
+			listener.Name = "listener-" + listener.Name
+		}
+
+		if httpsPorts.Has(svcPort.Port) {
+			if len(certificateIDs) == 0 {
+				return nil, fmt.Errorf("port %d is HTTPS, but no certificates are set via annotation %q", svcPort.Port, albCertificateIDsAnnotation)
+			}
+			listener.CertificateIDs = certificateIDs
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// albLocations returns the subnets the ALB is placed in: the annotated ones or one subnet per zone of the network.
+func (yc *Cloud) albLocations(ctx context.Context, service *v1.Service, networkID string) ([]*apploadbalancer.Location, error) {
+	var subnets []*yapi.Subnet
+	if value, ok := service.Annotations[albSubnetIDsAnnotation]; ok {
+		for _, subnetID := range strings.Split(value, ",") {
+			if subnetID = strings.TrimSpace(subnetID); len(subnetID) == 0 {
+				continue
+			}
+			subnet, err := yc.yandexService.VPCSvc.GetSubnet(ctx, subnetID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get subnet %q: %s", subnetID, err)
+			}
+			subnets = append(subnets, subnet)
+		}
+	} else {
+		networkSubnets, err := yc.yandexService.VPCSvc.ListNetworkSubnets(ctx, networkID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subnets of network %q: %s", networkID, err)
+		}
+		sort.Slice(networkSubnets, func(i, j int) bool { return networkSubnets[i].ID < networkSubnets[j].ID })
+		subnets = networkSubnets
+	}
+
+	var locations []*apploadbalancer.Location
+	zones := sets.New[string]()
+	for _, subnet := range subnets {
+		if zones.Has(subnet.ZoneID) {
+			continue
+		}
+		zones.Insert(subnet.ZoneID)
+		locations = append(locations, &apploadbalancer.Location{ZoneId: subnet.ZoneID, SubnetId: subnet.ID})
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("no subnets to place the Application Load Balancer in")
+	}
+
+	return locations, nil
+}
+
+// albHealthCheck converts the health check of the Nodes to an ALB backend health check,
+// TCP checks become stream checks without a payload.
+func albHealthCheck(healthCheck *loadbalancer.HealthCheck, host string) *apploadbalancer.HealthCheck {
+	ret := &apploadbalancer.HealthCheck{
+		Timeout:            healthCheck.Timeout,
+		Interval:           healthCheck.Interval,
+		HealthyThreshold:   healthCheck.HealthyThreshold,
+		UnhealthyThreshold: healthCheck.UnhealthyThreshold,
+	}
+
+	if tcpOptions := healthCheck.GetTcpOptions(); tcpOptions != nil {
+		ret.HealthcheckPort = tcpOptions.Port
+		ret.Healthcheck = &apploadbalancer.HealthCheck_Stream{Stream: &apploadbalancer.HealthCheck_StreamHealthCheck{}}
+		return ret
+	}
+
+	ret.HealthcheckPort = healthCheck.GetHttpOptions().GetPort()
+	ret.Healthcheck = &apploadbalancer.HealthCheck_Http{Http: &apploadbalancer.HealthCheck_HttpHealthCheck{
+		Host: host,
+		Path: healthCheck.GetHttpOptions().GetPath(),
+	}}
+	return ret
+}
+
+// albLoadBalancerStatus builds the Service status out of the ALB listeners.
+// The ALB proxies the traffic, so the ingress points are reported in the Proxy mode.
+func albLoadBalancerStatus(alb *apploadbalancer.LoadBalancer) *v1.LoadBalancerStatus {
+	var ingresses []v1.LoadBalancerIngress
+	ingressByAddress := make(map[netip.Addr]int)
+
+	for _, listener := range alb.GetListeners() {
+		for _, endpoint := range listener.Endpoints {
+			for _, address := range endpoint.Addresses {
+				var value string
+				switch {
+				case address.GetExternalIpv4Address() != nil:
+					value = address.GetExternalIpv4Address().Address
+				case address.GetInternalIpv4Address() != nil:
+					value = address.GetInternalIpv4Address().Address
+				case address.GetExternalIpv6Address() != nil:
+					value = address.GetExternalIpv6Address().Address
+				}
+
+				addr, err := netip.ParseAddr(value)
+				if err != nil {
+					log.Printf("Skipping listener %q of ALB %q with malformed address %q", listener.Name, alb.Name, value)
+					continue
+				}
+
+				index, ok := ingressByAddress[addr]
+				if !ok {
+					ipMode := v1.LoadBalancerIPModeProxy
+					ingresses = append(ingresses, v1.LoadBalancerIngress{IP: addr.String(), IPMode: &ipMode})
+					index = len(ingresses) - 1
+					ingressByAddress[addr] = index
+				}
+
+				for _, port := range endpoint.Ports {
+					ingresses[index].Ports = append(ingresses[index].Ports, v1.PortStatus{Port: int32(port), Protocol: v1.ProtocolTCP})
+				}
+			}
+		}
+	}
+
+	sortLoadBalancerIngresses(ingresses)
+
+	return &v1.LoadBalancerStatus{Ingress: ingresses}
+}
@@ -0,0 +1,118 @@
+package yandex
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+func TestALBListeners(t *testing.T) {
+	newService := func(annotations map[string]string, ports ...v1.ServicePort) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc", Annotations: annotations},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, Ports: ports},
+		}
+	}
+	http := v1.ServicePort{Name: "http", Port: 80, NodePort: 30080, Protocol: v1.ProtocolTCP}
+	https := v1.ServicePort{Port: 443, NodePort: 30443, Protocol: v1.ProtocolTCP}
+
+	tests := []struct {
+		name     string
+		service  *v1.Service
+		expected []yapi.ALBListener
+		wantErr  bool
+	}{
+		{
+			name:    "default HTTPS port",
+			service: newService(map[string]string{albCertificateIDsAnnotation: "cert1, cert2"}, http, https),
+			expected: []yapi.ALBListener{
+				{Name: "http", Port: 80, BackendPort: 30080},
+				{Name: "listener-1", Port: 443, BackendPort: 30443, CertificateIDs: []string{"cert1", "cert2"}},
+			},
+		},
+		{
+			name: "annotated HTTPS ports",
+			service: newService(map[string]string{
+				albCertificateIDsAnnotation: "cert1",
+				albHTTPSPortsAnnotation:     "80",
+			}, http, https),
+			expected: []yapi.ALBListener{
+				{Name: "http", Port: 80, BackendPort: 30080, CertificateIDs: []string{"cert1"}},
+				{Name: "listener-1", Port: 443, BackendPort: 30443},
+			},
+		},
+		{
+			name:    "port name starting with a digit",
+			service: newService(nil, v1.ServicePort{Name: "8080-http", Port: 8080, NodePort: 30081, Protocol: v1.ProtocolTCP}),
+			expected: []yapi.ALBListener{
+				{Name: "listener-8080-http", Port: 8080, BackendPort: 30081},
+			},
+		},
+		{
+			name:    "HTTPS without certificates",
+			service: newService(nil, https),
+			wantErr: true,
+		},
+		{
+			name:    "UDP",
+			service: newService(nil, v1.ServicePort{Port: 53, NodePort: 30053, Protocol: v1.ProtocolUDP}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listeners, err := albListeners(tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(listeners, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, listeners)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerType(t *testing.T) {
+	classes, err := parseLoadBalancerClasses(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yc := &Cloud{config: CloudConfig{LoadBalancerClasses: classes}}
+
+	albClass := loadBalancerClassALB
+	tests := []struct {
+		name        string
+		class       *string
+		annotations map[string]string
+		expected    string
+		wantErr     bool
+	}{
+		{name: "default", expected: loadBalancerTypeNLB},
+		{name: "class", class: &albClass, expected: loadBalancerTypeALB},
+		{name: "annotation", annotations: map[string]string{loadBalancerTypeAnnotation: "alb"}, expected: loadBalancerTypeALB},
+		{name: "annotation over class", class: &albClass, annotations: map[string]string{loadBalancerTypeAnnotation: "NLB"}, expected: loadBalancerTypeNLB},
+		{name: "unsupported", annotations: map[string]string{loadBalancerTypeAnnotation: "gwlb"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "svc", Annotations: tt.annotations},
+				Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, LoadBalancerClass: tt.class},
+			}
+
+			lbType, err := yc.loadBalancerType(service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if lbType != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, lbType)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package yandex
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Built-in load balancer classes, they can be customized by profiles with the same names.
+const (
+	loadBalancerClassNLBExternal = "yandex.cloud/nlb-external"
+	loadBalancerClassNLBInternal = "yandex.cloud/nlb-internal"
+	loadBalancerClassALB         = "yandex.cloud/alb"
+)
+
+// Load balancer implementations.
+const (
+	loadBalancerTypeNLB = "NLB"
+	loadBalancerTypeALB = "ALB"
+)
+
+// loadBalancerClass is a profile applied to the Services with the matching spec.loadBalancerClass.
+// Service annotations take precedence over the profile values.
+type loadBalancerClass struct {
+	// Name is the spec.loadBalancerClass value of the Services the profile applies to.
+	Name string `json:"name"`
+	// Type is NLB (default) or ALB.
+	Type string `json:"type,omitempty"`
+
+	// Internal makes listeners get private addresses in ListenerSubnetID.
+	Internal bool `json:"internal,omitempty"`
+	// ListenerSubnetID defaults to lbListenerSubnetID.
+	ListenerSubnetID string `json:"listenerSubnetID,omitempty"`
+	// TargetGroupNetworkID defaults to lbTargetGroupNetworkID.
+	TargetGroupNetworkID string `json:"targetGroupNetworkID,omitempty"`
+
+	HealthCheck loadBalancerClassHealthCheck `json:"healthCheck,omitempty"`
+
+	// Labels are set on the load balancers of the class.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type loadBalancerClassHealthCheck struct {
+	IntervalSeconds    int `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds     int `json:"timeoutSeconds,omitempty"`
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+	HealthyThreshold   int `json:"healthyThreshold,omitempty"`
+
+	// Protocol is HTTP (default) or TCP.
+	Protocol string `json:"protocol,omitempty"`
+	// Port and Path default to the kube-proxy healthz endpoint, Host is only sent by ALB health checks.
+	Port int    `json:"port,omitempty"`
+	Path string `json:"path,omitempty"`
+	Host string `json:"host,omitempty"`
+}
+
+// parseLoadBalancerClasses merges the configured profiles with the built-in classes.
+func parseLoadBalancerClasses(classes []loadBalancerClass) (map[string]*loadBalancerClass, error) {
+	ret := map[string]*loadBalancerClass{
+		loadBalancerClassNLBExternal: {Name: loadBalancerClassNLBExternal, Type: loadBalancerTypeNLB},
+		loadBalancerClassNLBInternal: {Name: loadBalancerClassNLBInternal, Type: loadBalancerTypeNLB, Internal: true},
+		loadBalancerClassALB:         {Name: loadBalancerClassALB, Type: loadBalancerTypeALB},
+	}
+
+	seen := make(map[string]struct{}, len(classes))
+	for i := range classes {
+		class := classes[i]
+
+		if errs := validation.IsQualifiedName(class.Name); len(errs) > 0 || !strings.Contains(class.Name, "/") {
+			return nil, fmt.Errorf("invalid load balancer class name %q: must be a label-style key with a prefix", class.Name)
+		}
+		if _, ok := seen[class.Name]; ok {
+			return nil, fmt.Errorf("duplicate load balancer class %q", class.Name)
+		}
+		seen[class.Name] = struct{}{}
+
+		switch class.Type {
+		case "":
+			class.Type = loadBalancerTypeNLB
+		case loadBalancerTypeNLB, loadBalancerTypeALB:
+		default:
+			return nil, fmt.Errorf("load balancer class %q has unsupported type %q, expected %q or %q", class.Name, class.Type, loadBalancerTypeNLB, loadBalancerTypeALB)
+		}
+
+		class.HealthCheck.Protocol = strings.ToUpper(class.HealthCheck.Protocol)
+		hc := class.HealthCheck
+		if hc.IntervalSeconds < 0 || hc.TimeoutSeconds < 0 || hc.UnhealthyThreshold < 0 || hc.HealthyThreshold < 0 {
+			return nil, fmt.Errorf("load balancer class %q health check values must not be negative", class.Name)
+		}
+		if err := validateHealthCheckOptions(hc.Protocol, hc.Port, hc.Path, hc.Host); err != nil {
+			return nil, fmt.Errorf("load balancer class %q: %s", class.Name, err)
+		}
+		if len(hc.Host) > 0 && class.Type != loadBalancerTypeALB {
+			return nil, fmt.Errorf("load balancer class %q: the health check Host is only supported by the %q type", class.Name, loadBalancerTypeALB)
+		}
+
+		ret[class.Name] = &class
+	}
+
+	return ret, nil
+}
+
+// loadBalancerClassOf returns the profile of the Service, nil is returned for Services without a class.
+// ok is false if the class belongs to another controller.
+func (yc *Cloud) loadBalancerClassOf(service *v1.Service) (class *loadBalancerClass, ok bool) {
+	if service.Spec.LoadBalancerClass == nil {
+		return nil, true
+	}
+
+	class, ok = yc.config.LoadBalancerClasses[*service.Spec.LoadBalancerClass]
+	return class, ok
+}
+
+// managesLoadBalancer tells whether the load balancer of the Service is managed by the CCM.
+func (yc *Cloud) managesLoadBalancer(service *v1.Service) bool {
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return false
+	}
+
+	_, ok := yc.loadBalancerClassOf(service)
+	return ok
+}
+
+// loadBalancerType returns the implementation of the Service load balancer,
+// the annotation takes precedence over the type of the class.
+func (yc *Cloud) loadBalancerType(service *v1.Service) (string, error) {
+	if value, ok := service.Annotations[loadBalancerTypeAnnotation]; ok {
+		switch strings.ToUpper(value) {
+		case loadBalancerTypeNLB:
+			return loadBalancerTypeNLB, nil
+		case loadBalancerTypeALB:
+			return loadBalancerTypeALB, nil
+		default:
+			return "", fmt.Errorf("unsupported value of annotation %q: %q, expected %q or %q", loadBalancerTypeAnnotation, value, "nlb", "alb")
+		}
+	}
+
+	if class, _ := yc.loadBalancerClassOf(service); class != nil {
+		return class.Type, nil
+	}
+
+	return loadBalancerTypeNLB, nil
+}
@@ -0,0 +1,344 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// loadBalancerCleanupFinalizer protects the load balancers of classes handled by the CCM.
+	// The upstream finalizer can't be used, the upstream controller deletes the load balancers of Services it is set on
+	// if they have a class.
+	loadBalancerCleanupFinalizer = "yandex.cloud/load-balancer-cleanup"
+
+	// toBeDeletedTaint is set by the cluster autoscaler on the Nodes it is about to remove.
+	toBeDeletedTaint = "ToBeDeletedByClusterAutoscaler"
+
+	loadBalancerClassResyncPeriod = 5 * time.Minute
+	loadBalancerClassSyncTimeout  = 10 * time.Minute
+)
+
+// LoadBalancerClassController provisions load balancers for the Services with a load balancer class handled by the CCM.
+// The upstream service controller ignores all Services with spec.loadBalancerClass set,
+// so the classes are reconciled here, the same way the upstream controller does for Services without a class.
+type LoadBalancerClassController struct {
+	cloud         *Cloud
+	balancer      cloudprovider.LoadBalancer
+	client        kubernetes.Interface
+	serviceLister listersv1.ServiceLister
+	nodeLister    listersv1.NodeLister
+
+	queue workqueue.TypedRateLimitingInterface[string]
+}
+
+func newLoadBalancerClassController(cloud *Cloud, client kubernetes.Interface,
+	serviceInformer coreinformers.ServiceInformer, nodeInformer coreinformers.NodeInformer) *LoadBalancerClassController {
+
+	c := &LoadBalancerClassController{
+		cloud:         cloud,
+		balancer:      cloud,
+		client:        client,
+		serviceLister: serviceInformer.Lister(),
+		nodeLister:    nodeInformer.Lister(),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "load-balancer-class"},
+		),
+	}
+
+	_, _ = serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueService,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldService, newService := oldObj.(*v1.Service), newObj.(*v1.Service)
+			if oldService.ResourceVersion != newService.ResourceVersion {
+				c.enqueueService(newObj)
+			}
+		},
+	})
+	_, _ = nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { c.enqueueAll() },
+		DeleteFunc: func(interface{}) { c.enqueueAll() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if loadBalancerNodeKey(oldObj.(*v1.Node)) != loadBalancerNodeKey(newObj.(*v1.Node)) {
+				c.enqueueAll()
+			}
+		},
+	})
+
+	return c
+}
+
+// Run processes Services until stop is closed, all Services are re-synchronized every resync period.
+func (c *LoadBalancerClassController) Run(stop <-chan struct{}) {
+	defer c.queue.ShutDown()
+
+	go wait.Until(func() {
+		for c.processNextItem() {
+		}
+	}, time.Second, stop)
+
+	wait.Until(c.enqueueAll, loadBalancerClassResyncPeriod, stop)
+}
+
+func (c *LoadBalancerClassController) enqueueService(obj interface{}) {
+	service, ok := obj.(*v1.Service)
+	if !ok || !c.ownsService(service) {
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(service)
+	if err != nil {
+		klog.Errorf("failed to get the key of Service %s/%s: %s", service.Namespace, service.Name, err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+func (c *LoadBalancerClassController) enqueueAll() {
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list Services: %s", err)
+		return
+	}
+
+	for _, service := range services {
+		c.enqueueService(service)
+	}
+}
+
+func (c *LoadBalancerClassController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadBalancerClassSyncTimeout)
+	defer cancel()
+
+	if err := c.sync(ctx, key); err != nil {
+		klog.Errorf("failed to synchronize load balancer of Service %s: %s", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+
+	return true
+}
+
+func (c *LoadBalancerClassController) sync(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !c.ownsService(service) {
+		return nil
+	}
+
+	// the class is cleared together with the type, so only the finalizer is left on such Services
+	if service.DeletionTimestamp != nil || service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return c.deleteLoadBalancer(ctx, service)
+	}
+	if service.Spec.LoadBalancerClass == nil {
+		// the Service has been switched back to the default class, the upstream controller takes the load balancer over
+		return c.updateFinalizer(ctx, service, false)
+	}
+
+	if err := c.updateFinalizer(ctx, service, true); err != nil {
+		return err
+	}
+
+	nodes, err := c.loadBalancerNodes()
+	if err != nil {
+		return err
+	}
+
+	status, err := c.balancer.EnsureLoadBalancer(ctx, c.cloud.config.ClusterName, service, nodes)
+	if err != nil {
+		c.recordEvent(service, v1.EventTypeWarning, "SyncLoadBalancerFailed", "Error syncing load balancer: %s", err)
+		return err
+	}
+
+	changed, err := c.updateStatus(ctx, service, status)
+	if err != nil {
+		return err
+	}
+	if changed {
+		c.recordEvent(service, v1.EventTypeNormal, "EnsuredLoadBalancer", "Ensured load balancer of class %q", *service.Spec.LoadBalancerClass)
+	}
+
+	return nil
+}
+
+func (c *LoadBalancerClassController) deleteLoadBalancer(ctx context.Context, service *v1.Service) error {
+	if !hasLoadBalancerCleanupFinalizer(service) {
+		return nil
+	}
+
+	c.recordEvent(service, v1.EventTypeNormal, "DeletingLoadBalancer", "Deleting load balancer")
+	if err := c.balancer.EnsureLoadBalancerDeleted(ctx, c.cloud.config.ClusterName, service); err != nil {
+		c.recordEvent(service, v1.EventTypeWarning, "DeleteLoadBalancerFailed", "Error deleting load balancer: %s", err)
+		return err
+	}
+
+	if _, err := c.updateStatus(ctx, service, &v1.LoadBalancerStatus{}); err != nil {
+		return err
+	}
+	if err := c.updateFinalizer(ctx, service, false); err != nil {
+		return err
+	}
+	c.recordEvent(service, v1.EventTypeNormal, "DeletedLoadBalancer", "Deleted load balancer")
+
+	return nil
+}
+
+// loadBalancerNodes returns the Nodes eligible for load balancer target groups.
+func (c *LoadBalancerClassController) loadBalancerNodes() ([]*v1.Node, error) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*v1.Node
+	for _, node := range nodes {
+		if includeLoadBalancerNode(node) {
+			ret = append(ret, node)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no Nodes eligible for load balancers")
+	}
+
+	return ret, nil
+}
+
+func includeLoadBalancerNode(node *v1.Node) bool {
+	if _, ok := node.Labels[v1.LabelNodeExcludeBalancers]; ok {
+		return false
+	}
+	if node.Spec.ProviderID == "" {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == toBeDeletedTaint {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loadBalancerNodeKey changes whenever a Node change affects load balancers.
+func loadBalancerNodeKey(node *v1.Node) string {
+	return fmt.Sprintf("%s/%t", fromNodeToInterfaceSlice([]*v1.Node{node})[0], includeLoadBalancerNode(node))
+}
+
+func (c *LoadBalancerClassController) updateStatus(ctx context.Context, service *v1.Service, status *v1.LoadBalancerStatus) (bool, error) {
+	return updateLoadBalancerStatus(ctx, c.client, service, status)
+}
+
+// updateLoadBalancerStatus sets the load balancer status of the Service unless it is already up to date.
+func updateLoadBalancerStatus(ctx context.Context, client kubernetes.Interface, service *v1.Service, status *v1.LoadBalancerStatus) (changed bool, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(current.Status.LoadBalancer, *status) {
+			return nil
+		}
+
+		updated := current.DeepCopy()
+		updated.Status.LoadBalancer = *status
+		_, err = client.CoreV1().Services(service.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+		changed = err == nil
+		return err
+	})
+
+	return changed, err
+}
+
+func (c *LoadBalancerClassController) updateFinalizer(ctx context.Context, service *v1.Service, present bool) error {
+	if hasLoadBalancerCleanupFinalizer(service) == present {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.client.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if hasLoadBalancerCleanupFinalizer(current) == present {
+			return nil
+		}
+
+		updated := current.DeepCopy()
+		if present {
+			updated.Finalizers = append(updated.Finalizers, loadBalancerCleanupFinalizer)
+		} else {
+			var finalizers []string
+			for _, finalizer := range updated.Finalizers {
+				if finalizer != loadBalancerCleanupFinalizer {
+					finalizers = append(finalizers, finalizer)
+				}
+			}
+			updated.Finalizers = finalizers
+		}
+
+		_, err = c.client.CoreV1().Services(service.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (c *LoadBalancerClassController) recordEvent(service *v1.Service, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.cloud.recorder != nil {
+		c.cloud.recorder.Eventf(service, eventType, reason, messageFmt, args...)
+	}
+}
+
+// ownsService tells whether the Service has a class handled by the CCM or a load balancer left to clean up.
+func (c *LoadBalancerClassController) ownsService(service *v1.Service) bool {
+	if hasLoadBalancerCleanupFinalizer(service) {
+		return true
+	}
+	if service.Spec.LoadBalancerClass == nil {
+		return false
+	}
+
+	_, ok := c.cloud.loadBalancerClassOf(service)
+	return ok
+}
+
+func hasLoadBalancerCleanupFinalizer(service *v1.Service) bool {
+	for _, finalizer := range service.Finalizers {
+		if finalizer == loadBalancerCleanupFinalizer {
+			return true
+		}
+	}
+
+	return false
+}
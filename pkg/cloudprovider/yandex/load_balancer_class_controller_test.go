@@ -0,0 +1,126 @@
+package yandex
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+// fakeBalancer records the Services it has been called for.
+type fakeBalancer struct {
+	cloudprovider.LoadBalancer
+
+	ensured []string
+	deleted []string
+	nodes   int
+}
+
+func (f *fakeBalancer) EnsureLoadBalancer(_ context.Context, _ string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	f.ensured = append(f.ensured, service.Name)
+	f.nodes = len(nodes)
+	return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}}, nil
+}
+
+func (f *fakeBalancer) EnsureLoadBalancerDeleted(_ context.Context, _ string, service *v1.Service) error {
+	f.deleted = append(f.deleted, service.Name)
+	return nil
+}
+
+func newClassService(name string, class *string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, LoadBalancerClass: class},
+	}
+}
+
+func TestLoadBalancerClassController(t *testing.T) {
+	internalClass, foreignClass := loadBalancerClassNLBInternal, "metallb.io/metallb"
+	owned := newClassService("owned", &internalClass)
+	foreign := newClassService("foreign", &foreignClass)
+	classless := newClassService("classless", nil)
+
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "vm1"}, Spec: v1.NodeSpec{ProviderID: "yandex://id1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "vm2", Labels: map[string]string{v1.LabelNodeExcludeBalancers: ""}}, Spec: v1.NodeSpec{ProviderID: "yandex://id2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "vm3"}},
+	}
+
+	yc := newTestCloud(nil)
+	yc.config.LoadBalancerClasses, _ = parseLoadBalancerClasses(nil)
+
+	client := fake.NewSimpleClientset(owned, foreign, classless)
+	serviceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, service := range []*v1.Service{owned, foreign, classless} {
+		if err := serviceIndexer.Add(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, node := range nodes {
+		if err := nodeIndexer.Add(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	balancer := &fakeBalancer{}
+	c := &LoadBalancerClassController{
+		cloud:         yc,
+		balancer:      balancer,
+		client:        client,
+		serviceLister: listersv1.NewServiceLister(serviceIndexer),
+		nodeLister:    listersv1.NewNodeLister(nodeIndexer),
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"default/owned", "default/foreign", "default/classless"} {
+		if err := c.sync(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(balancer.ensured) != 1 || balancer.ensured[0] != "owned" {
+		t.Fatalf("only the Service of a known class should be handled, got %v", balancer.ensured)
+	}
+	if balancer.nodes != 1 {
+		t.Errorf("excluded Nodes and Nodes without ProviderID should be skipped, got %d Nodes", balancer.nodes)
+	}
+
+	updated, err := client.CoreV1().Services("default").Get(ctx, "owned", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasLoadBalancerCleanupFinalizer(updated) {
+		t.Error("the finalizer should be added")
+	}
+	if len(updated.Status.LoadBalancer.Ingress) != 1 {
+		t.Errorf("the status should be updated, got %+v", updated.Status.LoadBalancer)
+	}
+
+	// the load balancer is removed once the Service is deleted
+	now := metav1.Now()
+	updated.DeletionTimestamp = &now
+	if err := serviceIndexer.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.sync(ctx, "default/owned"); err != nil {
+		t.Fatal(err)
+	}
+	if len(balancer.deleted) != 1 {
+		t.Errorf("the load balancer should be deleted, got %v", balancer.deleted)
+	}
+
+	updated, err = client.CoreV1().Services("default").Get(ctx, "owned", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasLoadBalancerCleanupFinalizer(updated) {
+		t.Error("the finalizer should be removed")
+	}
+}
@@ -0,0 +1,116 @@
+package yandex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// allowDisruptionAnnotation lets the disruptive load balancer changes of the Service through the disruption guard.
+const allowDisruptionAnnotation = "yandex.cpi.flant.com/allow-disruption"
+
+// disruptionGuard refuses load balancer changes that break the traffic of a Service:
+// re-creation, address changes and listener removal. They are only made for the Services with
+// the allow annotation or within maintenance windows.
+type disruptionGuard struct {
+	maintenanceWindows []maintenanceWindow
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type maintenanceWindow struct {
+	// days the window starts on, the window starts every day if it is empty
+	days     map[time.Weekday]bool
+	start    time.Duration
+	duration time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseDisruptionGuard returns nil if the guard is disabled.
+func parseDisruptionGuard(cfg disruptionGuardConfig) (*disruptionGuard, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	guard := &disruptionGuard{now: time.Now}
+	for i, windowCfg := range cfg.MaintenanceWindows {
+		window := maintenanceWindow{days: make(map[time.Weekday]bool), duration: windowCfg.Duration.Duration}
+
+		for _, day := range windowCfg.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("maintenance window %d: unsupported day %q, expected one of Sun, Mon, Tue, Wed, Thu, Fri and Sat", i, day)
+			}
+			window.days[weekday] = true
+		}
+
+		start, err := time.Parse("15:04", windowCfg.Start)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: start %q is not a HH:MM time: %s", i, windowCfg.Start, err)
+		}
+		window.start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+
+		if window.duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %d: duration must be positive", i)
+		}
+
+		guard.maintenanceWindows = append(guard.maintenanceWindows, window)
+	}
+
+	return guard, nil
+}
+
+// inMaintenanceWindow tells whether t is within one of the windows, the windows are in UTC.
+func (g *disruptionGuard) inMaintenanceWindow(t time.Time) bool {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	for _, window := range g.maintenanceWindows {
+		// windows started on the previous days may still be open
+		for daysAgo := 0; time.Duration(daysAgo)*24*time.Hour < window.start+window.duration; daysAgo++ {
+			day := midnight.AddDate(0, 0, -daysAgo)
+			if len(window.days) > 0 && !window.days[day.Weekday()] {
+				continue
+			}
+
+			begin := day.Add(window.start)
+			if !t.Before(begin) && t.Before(begin.Add(window.duration)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// guardDisruption returns an error and records a Warning event if the disruptive change of the Service load balancer
+// is not allowed. change describes it, e.g. "remove listeners".
+func (yc *Cloud) guardDisruption(service *v1.Service, change string) error {
+	guard := yc.config.DisruptionGuard
+	if guard == nil {
+		return nil
+	}
+	if allowed, _ := strconv.ParseBool(service.Annotations[allowDisruptionAnnotation]); allowed {
+		return nil
+	}
+	if guard.inMaintenanceWindow(guard.now()) {
+		return nil
+	}
+
+	yc.recordLoadBalancerEvent(service, v1.EventTypeWarning, "DisruptiveChangeRefused",
+		"Refusing to %s outside of maintenance windows, set annotation %q to \"true\" to allow it", change, allowDisruptionAnnotation)
+	return fmt.Errorf("refusing to %s of Service %s/%s: disruptive load balancer changes are not allowed", change, service.Namespace, service.Name)
+}
@@ -0,0 +1,188 @@
+package yandex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	//nolint:staticcheck // Ignore SA1019. Need to keep deprecated package for compatibility.
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	ycsdkoperation "github.com/yandex-cloud/go-sdk/operation"
+	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+func TestParseDisruptionGuard(t *testing.T) {
+	window := func(start string, duration time.Duration, days ...string) maintenanceWindowConfig {
+		return maintenanceWindowConfig{Days: days, Start: start, Duration: metav1.Duration{Duration: duration}}
+	}
+
+	tests := []struct {
+		name     string
+		cfg      disruptionGuardConfig
+		disabled bool
+		wantErr  bool
+	}{
+		{name: "disabled", cfg: disruptionGuardConfig{MaintenanceWindows: []maintenanceWindowConfig{window("bad", 0)}}, disabled: true},
+		{name: "no windows", cfg: disruptionGuardConfig{Enabled: true}},
+		{name: "valid", cfg: disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{window("02:00", time.Hour, "Sat", "sun")}}},
+		{name: "bad day", cfg: disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{window("02:00", time.Hour, "Saturday")}}, wantErr: true},
+		{name: "bad start", cfg: disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{window("25:00", time.Hour)}}, wantErr: true},
+		{name: "no duration", cfg: disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{window("02:00", 0)}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard, err := parseDisruptionGuard(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && (guard == nil) != tt.disabled {
+				t.Errorf("expected disabled %t, got guard %+v", tt.disabled, guard)
+			}
+		})
+	}
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	guard, err := parseDisruptionGuard(disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{
+		// Saturday 23:00 to Sunday 01:00
+		{Days: []string{"Sat"}, Start: "23:00", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-06-01 is a Saturday
+	tests := []struct {
+		at       time.Time
+		expected bool
+	}{
+		{at: time.Date(2024, 6, 1, 22, 59, 0, 0, time.UTC), expected: false},
+		{at: time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC), expected: true},
+		{at: time.Date(2024, 6, 2, 0, 30, 0, 0, time.UTC), expected: true},
+		{at: time.Date(2024, 6, 2, 1, 0, 0, 0, time.UTC), expected: false},
+		{at: time.Date(2024, 6, 2, 23, 30, 0, 0, time.UTC), expected: false},
+		// Sunday 00:30 UTC
+		{at: time.Date(2024, 6, 2, 3, 30, 0, 0, time.FixedZone("MSK", 3*60*60)), expected: true},
+	}
+
+	for _, tt := range tests {
+		if actual := guard.inMaintenanceWindow(tt.at); actual != tt.expected {
+			t.Errorf("%s: expected %t, got %t", tt.at, tt.expected, actual)
+		}
+	}
+}
+
+func TestGuardDisruption(t *testing.T) {
+	guard, err := parseDisruptionGuard(disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{
+		{Start: "02:00", Duration: metav1.Duration{Duration: time.Hour}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newService := func(annotations map[string]string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc", Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name    string
+		guard   *disruptionGuard
+		now     time.Time
+		service *v1.Service
+		wantErr bool
+	}{
+		{name: "disabled", service: newService(nil)},
+		{name: "refused", guard: guard, now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), service: newService(nil), wantErr: true},
+		{name: "maintenance window", guard: guard, now: time.Date(2024, 6, 1, 2, 30, 0, 0, time.UTC), service: newService(nil)},
+		{
+			name:    "annotation",
+			guard:   guard,
+			now:     time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+			service: newService(map[string]string{allowDisruptionAnnotation: "true"}),
+		},
+		{
+			name:    "annotation false",
+			guard:   guard,
+			now:     time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+			service: newService(map[string]string{allowDisruptionAnnotation: "false"}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.guard != nil {
+				now := tt.now
+				tt.guard.now = func() time.Time { return now }
+			}
+			yc := &Cloud{config: CloudConfig{DisruptionGuard: tt.guard}}
+
+			err := yc.guardDisruption(tt.service, "remove listeners")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnsureNLBListenerRemovalGuard(t *testing.T) {
+	lbSvc := &fakeNetworkLoadBalancerService{}
+	cloudCtx := &yapi.CloudContext{
+		FolderID: "folder",
+		OperationWaiter: func(_ context.Context, origFunc func() (*operation.Operation, error)) (proto.Message, *ycsdkoperation.Operation, error) {
+			_, err := origFunc()
+			return lbSvc.last, nil, err
+		},
+	}
+
+	guard, err := parseDisruptionGuard(disruptionGuardConfig{Enabled: true, MaintenanceWindows: []maintenanceWindowConfig{
+		{Start: "02:00", Duration: metav1.Duration{Duration: time.Hour}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+
+	yc := newTestCloud(nil)
+	yc.config.DisruptionGuard = guard
+	yc.yandexService.LbSvc = yapi.NewLoadBalancerService(lbSvc, nil, cloudCtx)
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "api", UID: "uid1"}}
+	lbName := defaultLoadBalancerName(service)
+	newSpecs := func(ports ...v1.ServicePort) []*loadbalancer.ListenerSpec {
+		specs, err := buildListenerSpecs(&v1.Service{Spec: v1.ServiceSpec{Ports: ports}}, loadBalancerParameters{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return specs
+	}
+	http := v1.ServicePort{Name: "http", Port: 80, NodePort: 30080, Protocol: v1.ProtocolTCP}
+	https := v1.ServicePort{Name: "https", Port: 443, NodePort: 30443, Protocol: v1.ProtocolTCP}
+
+	if _, err := yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(http, https), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// outside the maintenance window the listener is kept
+	if _, err := yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(http), nil); err == nil {
+		t.Fatal("should return non-nil err when removing a listener outside the maintenance window")
+	}
+	if listeners := lbSvc.lbs[0].Listeners; len(listeners) != 2 {
+		t.Fatalf("listeners should be kept, got %v", listeners)
+	}
+
+	now = time.Date(2024, 6, 1, 2, 30, 0, 0, time.UTC)
+	lb, err := yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(http), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lb.Listeners) != 1 || lb.Listeners[0].Name != "http" {
+		t.Errorf("listener should be removed in the maintenance window, got %v", lb.Listeners)
+	}
+}
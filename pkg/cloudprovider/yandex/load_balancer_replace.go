@@ -0,0 +1,201 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+// replacementLoadBalancerName is the temporary name of the NLB replacing the one of the Service.
+func replacementLoadBalancerName(lbName string) string {
+	return lbName + "-replacement"
+}
+
+// replacementTargetGroupName is the name of the copy of the index-th target group attached to the replacement NLB.
+// The cluster name keeps the copies among the target groups of the cluster, so that preempted Nodes are removed from them.
+func (yc *Cloud) replacementTargetGroupName(lbName string, index int) string {
+	return fmt.Sprintf("%s-%d-%s", lbName, index, yc.config.ClusterName)
+}
+
+// ensureNLB creates or updates the NLB of the Service.
+// The type of an NLB can't be changed in place, so it is replaced make-before-break: the replacement is created
+// under a temporary name and takes over the Service status once it has healthy targets,
+// only then the old NLB is deleted and the replacement is renamed.
+// A target group can't serve the same target port of two NLBs, so the replacement gets copies of the target groups,
+// which are swapped for the originals once the old NLB is deleted.
+// Every step is derived from the NLBs found in the cloud, so an interrupted replacement is resumed after a restart.
+func (yc *Cloud) ensureNLB(ctx context.Context, service *v1.Service, lbName string, labels map[string]string,
+	listenerSpecs []*loadbalancer.ListenerSpec, attachedTGs []*loadbalancer.AttachedTargetGroup) (*loadbalancer.NetworkLoadBalancer, error) {
+
+	lbSvc := yc.yandexService.LbSvc
+	nextName := replacementLoadBalancerName(lbName)
+
+	current, err := lbSvc.GetLbByName(ctx, lbName)
+	if err != nil {
+		return nil, err
+	}
+	next, err := lbSvc.GetLbByName(ctx, nextName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case current != nil && current.Type == yapi.NetworkLoadBalancerType(listenerSpecs):
+		if removed := yapi.ListenersToRemove(current, listenerSpecs); len(removed) > 0 {
+			var names []string
+			for _, listener := range removed {
+				names = append(names, listener.Name)
+			}
+			if err := yc.guardDisruption(service, fmt.Sprintf("remove listeners %s of LB %q", strings.Join(names, ", "), lbName)); err != nil {
+				return nil, err
+			}
+		}
+		if next != nil {
+			// the type has been changed back before the replacement took over
+			log.Printf("Type of LB %q matches again, deleting replacement LB %q", lbName, nextName)
+			if err := lbSvc.RemoveLBByName(ctx, nextName); err != nil {
+				return nil, err
+			}
+		}
+		lb, err := lbSvc.CreateOrUpdateLB(ctx, lbName, labels, listenerSpecs, attachedTGs)
+		if err != nil {
+			return nil, err
+		}
+		// the copies are left by a replacement interrupted before they were removed
+		if next != nil || !attachesOnly(current, attachedTGs) {
+			if err := yc.removeReplacementTGs(ctx, lbName); err != nil {
+				return nil, err
+			}
+		}
+		return lb, nil
+	case current == nil && next != nil:
+		// the old NLB has already been deleted
+		if _, err := lbSvc.RenameLB(ctx, next, lbName); err != nil {
+			return nil, err
+		}
+		return yc.handOverTGs(ctx, lbName, labels, listenerSpecs, attachedTGs)
+	case current == nil:
+		return lbSvc.CreateOrUpdateLB(ctx, lbName, labels, listenerSpecs, attachedTGs)
+	}
+
+	// the replacement gets a new address
+	if err := yc.guardDisruption(service, fmt.Sprintf("replace %s LB %q with a %s one", current.Type, lbName, yapi.NetworkLoadBalancerType(listenerSpecs))); err != nil {
+		return nil, err
+	}
+	if next == nil {
+		yc.recordLoadBalancerEvent(service, v1.EventTypeNormal, "ReplacingLoadBalancer",
+			"Replacing %s load balancer %q with a %s one", current.Type, lbName, yapi.NetworkLoadBalancerType(listenerSpecs))
+	}
+	nextTGs, err := yc.ensureReplacementTGs(ctx, lbName, attachedTGs)
+	if err != nil {
+		return nil, err
+	}
+	next, err = lbSvc.CreateOrUpdateLB(ctx, nextName, labels, listenerSpecs, nextTGs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tg := range nextTGs {
+		healthy, err := lbSvc.HasHealthyTargets(ctx, next.Id, tg.TargetGroupId)
+		if err != nil {
+			return nil, err
+		}
+		if !healthy {
+			return nil, fmt.Errorf("waiting for healthy targets of replacement LB %q, LB %q keeps serving the Service", nextName, lbName)
+		}
+	}
+
+	// the Service must point to the replacement before the old NLB stops serving it
+	if yc.client != nil {
+		if _, err := updateLoadBalancerStatus(ctx, yc.client, service, loadBalancerStatus(next)); err != nil {
+			return nil, fmt.Errorf("failed to switch the Service to replacement LB %q: %s", nextName, err)
+		}
+	}
+
+	if err := lbSvc.RemoveLBByName(ctx, lbName); err != nil {
+		return nil, err
+	}
+	if _, err := lbSvc.RenameLB(ctx, next, lbName); err != nil {
+		return nil, err
+	}
+	lb, err := yc.handOverTGs(ctx, lbName, labels, listenerSpecs, attachedTGs)
+	if err != nil {
+		return nil, err
+	}
+	yc.recordLoadBalancerEvent(service, v1.EventTypeNormal, "ReplacedLoadBalancer", "Replaced load balancer %q", lbName)
+
+	return lb, nil
+}
+
+// ensureReplacementTGs copies the targets of the attached target groups to the target groups of the replacement NLB.
+func (yc *Cloud) ensureReplacementTGs(ctx context.Context, lbName string, attachedTGs []*loadbalancer.AttachedTargetGroup) ([]*loadbalancer.AttachedTargetGroup, error) {
+	var ret []*loadbalancer.AttachedTargetGroup
+	for i, attached := range attachedTGs {
+		tg, err := yc.yandexService.LbSvc.GetTgByID(ctx, attached.TargetGroupId)
+		if err != nil {
+			return nil, err
+		}
+
+		tgID, err := yc.yandexService.LbSvc.CreateOrUpdateTG(ctx, yc.replacementTargetGroupName(lbName, i), tg.Targets)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &loadbalancer.AttachedTargetGroup{TargetGroupId: tgID, HealthChecks: attached.HealthChecks})
+	}
+
+	return ret, nil
+}
+
+// handOverTGs attaches the original target groups to the NLB that has replaced the old one and removes the copies.
+func (yc *Cloud) handOverTGs(ctx context.Context, lbName string, labels map[string]string,
+	listenerSpecs []*loadbalancer.ListenerSpec, attachedTGs []*loadbalancer.AttachedTargetGroup) (*loadbalancer.NetworkLoadBalancer, error) {
+
+	lb, err := yc.yandexService.LbSvc.CreateOrUpdateLB(ctx, lbName, labels, listenerSpecs, attachedTGs)
+	if err != nil {
+		return nil, err
+	}
+	if err := yc.removeReplacementTGs(ctx, lbName); err != nil {
+		return nil, err
+	}
+
+	return lb, nil
+}
+
+// removeReplacementTGs removes the target groups copied for the replacement of the NLB.
+func (yc *Cloud) removeReplacementTGs(ctx context.Context, lbName string) error {
+	for i := 0; ; i++ {
+		tg, err := yc.yandexService.LbSvc.GetTgByName(ctx, yc.replacementTargetGroupName(lbName, i))
+		if err != nil || tg == nil {
+			return err
+		}
+		if err := yc.yandexService.LbSvc.RemoveTGByID(ctx, tg.Id); err != nil {
+			return err
+		}
+	}
+}
+
+// attachesOnly tells whether all the target groups attached to the NLB are among the given ones.
+func attachesOnly(lb *loadbalancer.NetworkLoadBalancer, attachedTGs []*loadbalancer.AttachedTargetGroup) bool {
+	for _, attached := range lb.AttachedTargetGroups {
+		if !slices.ContainsFunc(attachedTGs, func(tg *loadbalancer.AttachedTargetGroup) bool {
+			return tg.TargetGroupId == attached.TargetGroupId
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (yc *Cloud) recordLoadBalancerEvent(service *v1.Service, eventType, reason, messageFmt string, args ...interface{}) {
+	if yc.recorder != nil {
+		yc.recorder.Eventf(service, eventType, reason, messageFmt, args...)
+	}
+}
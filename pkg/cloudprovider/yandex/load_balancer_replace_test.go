@@ -0,0 +1,271 @@
+package yandex
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	//nolint:staticcheck // Ignore SA1019. Need to keep deprecated package for compatibility.
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	ycsdkoperation "github.com/yandex-cloud/go-sdk/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+// fakeNetworkLoadBalancerService keeps NLBs in memory, last is the NLB changed by the last operation.
+// Like the API, it refuses to serve a target port of a target group by two NLBs.
+type fakeNetworkLoadBalancerService struct {
+	loadbalancer.NetworkLoadBalancerServiceClient
+
+	lbs     []*loadbalancer.NetworkLoadBalancer
+	last    *loadbalancer.NetworkLoadBalancer
+	healthy bool
+	created int
+}
+
+func (f *fakeNetworkLoadBalancerService) List(_ context.Context, in *loadbalancer.ListNetworkLoadBalancersRequest, _ ...grpc.CallOption) (*loadbalancer.ListNetworkLoadBalancersResponse, error) {
+	var ret []*loadbalancer.NetworkLoadBalancer
+	for _, lb := range f.lbs {
+		if in.Filter == `name = "`+lb.Name+`"` {
+			ret = append(ret, lb)
+		}
+	}
+
+	return &loadbalancer.ListNetworkLoadBalancersResponse{NetworkLoadBalancers: ret}, nil
+}
+
+// targetPortInUse tells whether another NLB serves one of the target ports with the target group.
+func (f *fakeNetworkLoadBalancerService) targetPortInUse(lbID, tgID string, targetPorts []int64) bool {
+	for _, lb := range f.lbs {
+		if lb.Id == lbID || !slices.ContainsFunc(lb.AttachedTargetGroups, func(tg *loadbalancer.AttachedTargetGroup) bool {
+			return tg.TargetGroupId == tgID
+		}) {
+			continue
+		}
+		for _, listener := range lb.Listeners {
+			if slices.Contains(targetPorts, listener.TargetPort) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f *fakeNetworkLoadBalancerService) Create(_ context.Context, in *loadbalancer.CreateNetworkLoadBalancerRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	var targetPorts []int64
+	for _, spec := range in.ListenerSpecs {
+		targetPorts = append(targetPorts, spec.TargetPort)
+	}
+	for _, tg := range in.AttachedTargetGroups {
+		if f.targetPortInUse("", tg.TargetGroupId, targetPorts) {
+			return nil, status.Errorf(codes.FailedPrecondition, "target group %q is used by another NLB on the same port", tg.TargetGroupId)
+		}
+	}
+
+	f.created++
+	f.last = &loadbalancer.NetworkLoadBalancer{
+		Id:                   "lb" + strconv.Itoa(f.created),
+		Name:                 in.Name,
+		Type:                 in.Type,
+		AttachedTargetGroups: in.AttachedTargetGroups,
+	}
+	for _, spec := range in.ListenerSpecs {
+		f.last.Listeners = append(f.last.Listeners, &loadbalancer.Listener{
+			Name:       spec.Name,
+			Address:    "10.0.0." + strconv.Itoa(f.created),
+			Port:       spec.Port,
+			Protocol:   spec.Protocol,
+			TargetPort: spec.TargetPort,
+			IpVersion:  loadbalancer.IpVersion_IPV4,
+		})
+	}
+	f.lbs = append(f.lbs, f.last)
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeNetworkLoadBalancerService) Update(_ context.Context, in *loadbalancer.UpdateNetworkLoadBalancerRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, lb := range f.lbs {
+		if lb.Id == in.NetworkLoadBalancerId {
+			lb.Name = in.Name
+			f.last = lb
+		}
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeNetworkLoadBalancerService) AttachTargetGroup(_ context.Context, in *loadbalancer.AttachNetworkLoadBalancerTargetGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, lb := range f.lbs {
+		if lb.Id != in.NetworkLoadBalancerId {
+			continue
+		}
+
+		var targetPorts []int64
+		for _, listener := range lb.Listeners {
+			targetPorts = append(targetPorts, listener.TargetPort)
+		}
+		if f.targetPortInUse(lb.Id, in.AttachedTargetGroup.TargetGroupId, targetPorts) {
+			return nil, status.Errorf(codes.FailedPrecondition, "target group %q is used by another NLB on the same port", in.AttachedTargetGroup.TargetGroupId)
+		}
+
+		lb.AttachedTargetGroups = append(lb.AttachedTargetGroups, in.AttachedTargetGroup)
+		f.last = lb
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeNetworkLoadBalancerService) DetachTargetGroup(_ context.Context, in *loadbalancer.DetachNetworkLoadBalancerTargetGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, lb := range f.lbs {
+		if lb.Id != in.NetworkLoadBalancerId {
+			continue
+		}
+
+		lb.AttachedTargetGroups = slices.DeleteFunc(lb.AttachedTargetGroups, func(tg *loadbalancer.AttachedTargetGroup) bool {
+			return tg.TargetGroupId == in.TargetGroupId
+		})
+		f.last = lb
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeNetworkLoadBalancerService) RemoveListener(_ context.Context, in *loadbalancer.RemoveNetworkLoadBalancerListenerRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, lb := range f.lbs {
+		if lb.Id != in.NetworkLoadBalancerId {
+			continue
+		}
+
+		lb.Listeners = slices.DeleteFunc(lb.Listeners, func(listener *loadbalancer.Listener) bool {
+			return listener.Name == in.ListenerName
+		})
+		f.last = lb
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeNetworkLoadBalancerService) Delete(_ context.Context, in *loadbalancer.DeleteNetworkLoadBalancerRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	var lbs []*loadbalancer.NetworkLoadBalancer
+	for _, lb := range f.lbs {
+		if lb.Id != in.NetworkLoadBalancerId {
+			lbs = append(lbs, lb)
+		}
+	}
+	f.lbs = lbs
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeNetworkLoadBalancerService) GetTargetStates(_ context.Context, _ *loadbalancer.GetTargetStatesRequest, _ ...grpc.CallOption) (*loadbalancer.GetTargetStatesResponse, error) {
+	status := loadbalancer.TargetState_UNHEALTHY
+	if f.healthy {
+		status = loadbalancer.TargetState_HEALTHY
+	}
+
+	return &loadbalancer.GetTargetStatesResponse{TargetStates: []*loadbalancer.TargetState{{Address: "10.1.0.1", Status: status}}}, nil
+}
+
+func TestEnsureNLBReplacement(t *testing.T) {
+	lbSvc := &fakeNetworkLoadBalancerService{}
+	tgSvc := &fakeTargetGroupService{targetGroups: []*loadbalancer.TargetGroup{{
+		Id:      "tg",
+		Name:    "clusternetwork",
+		Targets: []*loadbalancer.Target{{SubnetId: "subnet", Address: "10.1.0.1"}},
+	}}}
+	cloudCtx := &yapi.CloudContext{
+		FolderID: "folder",
+		OperationWaiter: func(_ context.Context, origFunc func() (*operation.Operation, error)) (proto.Message, *ycsdkoperation.Operation, error) {
+			lastTG := tgSvc.last
+			_, err := origFunc()
+			if tgSvc.last != lastTG {
+				return tgSvc.last, nil, err
+			}
+			return lbSvc.last, nil, err
+		},
+	}
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "api", UID: "uid1"}}
+	yc := newTestCloud(nil)
+	yc.config.ClusterName = "cluster"
+	yc.yandexService.LbSvc = yapi.NewLoadBalancerService(lbSvc, tgSvc, cloudCtx)
+	yc.client = fake.NewSimpleClientset(service)
+
+	lbName := defaultLoadBalancerName(service)
+	attachedTGs := []*loadbalancer.AttachedTargetGroup{{TargetGroupId: "tg", HealthChecks: []*loadbalancer.HealthCheck{
+		buildHealthCheck(service, loadBalancerParameters{}),
+	}}}
+	newSpecs := func(internal bool) []*loadbalancer.ListenerSpec {
+		specs, err := buildListenerSpecs(&v1.Service{Spec: v1.ServiceSpec{Ports: []v1.ServicePort{
+			{Name: "http", Port: 80, NodePort: 30080, Protocol: v1.ProtocolTCP},
+		}}}, loadBalancerParameters{internal: internal, listenerSubnetID: "subnet"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return specs
+	}
+
+	old, err := yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(true), attachedTGs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old NLB keeps serving until the replacement has healthy targets
+	if _, err := yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(false), attachedTGs); err == nil {
+		t.Fatal("should return non-nil err while the replacement is unhealthy")
+	}
+	if len(lbSvc.lbs) != 2 || lbSvc.lbs[0].Id != old.Id || lbSvc.lbs[1].Name != replacementLoadBalancerName(lbName) {
+		t.Fatalf("old NLB and its replacement should exist, got %v", lbSvc.lbs)
+	}
+	copyName := yc.replacementTargetGroupName(lbName, 0)
+	if tgs := lbSvc.lbs[1].AttachedTargetGroups; len(tgs) != 1 || tgs[0].TargetGroupId != copyName {
+		t.Fatalf("replacement should get a copy of the target group, got %v", tgs)
+	}
+	if len(tgSvc.targetGroups) != 2 || len(tgSvc.targetGroups[1].Targets) != 1 {
+		t.Fatalf("target group copy should have the targets, got %v", tgSvc.targetGroups)
+	}
+
+	lbSvc.healthy = true
+	lb, err := yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(false), attachedTGs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lbSvc.lbs) != 1 || lb.Name != lbName || lb.Type != loadbalancer.NetworkLoadBalancer_EXTERNAL || lb.Id == old.Id {
+		t.Fatalf("replacement should take over the name of the old NLB, got %v", lbSvc.lbs)
+	}
+	if tgs := lb.AttachedTargetGroups; len(tgs) != 1 || tgs[0].TargetGroupId != "tg" {
+		t.Errorf("original target group should be handed over to the replacement, got %v", tgs)
+	}
+	if len(tgSvc.targetGroups) != 1 || tgSvc.targetGroups[0].Id != "tg" {
+		t.Errorf("target group copy should be removed, got %v", tgSvc.targetGroups)
+	}
+
+	updated, err := yc.client.CoreV1().Services("ns").Get(context.Background(), "api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ingress := updated.Status.LoadBalancer.Ingress; len(ingress) != 1 || ingress[0].IP != lb.Listeners[0].Address {
+		t.Errorf("Service status should point to the replacement, got %+v", ingress)
+	}
+
+	// a replacement left without the old NLB by a restart is renamed
+	lbSvc.lbs[0].Name = replacementLoadBalancerName(lbName)
+	lb, err = yc.ensureNLB(context.Background(), service, lbName, nil, newSpecs(false), attachedTGs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lbSvc.lbs) != 1 || lb.Name != lbName {
+		t.Errorf("interrupted replacement should be renamed, got %v", lbSvc.lbs)
+	}
+}
@@ -0,0 +1,246 @@
+package yandex
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestLoadBalancerStatus(t *testing.T) {
+	lb := &loadbalancer.NetworkLoadBalancer{
+		Name: "lb",
+		Listeners: []*loadbalancer.Listener{
+			{Name: "https", Address: "2a02:6b8::1", Port: 443, Protocol: loadbalancer.Listener_TCP},
+			{Name: "dns", Address: "1.2.3.4", Port: 53, Protocol: loadbalancer.Listener_UDP},
+			{Name: "http", Address: "1.2.3.4", Port: 80, Protocol: loadbalancer.Listener_TCP},
+			{Name: "dns-tcp", Address: "1.2.3.4", Port: 53, Protocol: loadbalancer.Listener_TCP},
+			{Name: "broken", Address: "", Port: 8080, Protocol: loadbalancer.Listener_TCP},
+		},
+	}
+
+	ipMode := v1.LoadBalancerIPModeVIP
+	expected := &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{
+		{
+			IP:     "1.2.3.4",
+			IPMode: &ipMode,
+			Ports: []v1.PortStatus{
+				{Port: 53, Protocol: v1.ProtocolTCP},
+				{Port: 53, Protocol: v1.ProtocolUDP},
+				{Port: 80, Protocol: v1.ProtocolTCP},
+			},
+		},
+		{
+			IP:     "2a02:6b8::1",
+			IPMode: &ipMode,
+			Ports:  []v1.PortStatus{{Port: 443, Protocol: v1.ProtocolTCP}},
+		},
+	}}
+
+	status := loadBalancerStatus(lb)
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("unexpected status:\n%+v\nexpected:\n%+v", status, expected)
+	}
+
+	if status := loadBalancerStatus(&loadbalancer.NetworkLoadBalancer{}); len(status.Ingress) != 0 {
+		t.Errorf("LB without listeners should have no ingress points, got %+v", status.Ingress)
+	}
+}
+
+func TestGetLoadBalancerParametersClass(t *testing.T) {
+	classes, err := parseLoadBalancerClasses([]loadBalancerClass{{
+		Name:                 "example.com/db",
+		Internal:             true,
+		ListenerSubnetID:     "db-subnet",
+		TargetGroupNetworkID: "db-network",
+		HealthCheck:          loadBalancerClassHealthCheck{IntervalSeconds: 10, HealthyThreshold: 3},
+		Labels:               map[string]string{"team": "db"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yc := newTestCloud(nil)
+	yc.config.lbListenerSubnetID = "default-subnet"
+	yc.config.lbTgNetworkID = "default-network"
+	yc.config.LoadBalancerClasses = classes
+
+	class := "example.com/db"
+	service := &v1.Service{Spec: v1.ServiceSpec{LoadBalancerClass: &class}}
+	service.Annotations = map[string]string{healthcheckIntervalSeconds: "5"}
+
+	params, err := yc.getLoadBalancerParameters(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !params.internal || params.listenerSubnetID != "db-subnet" || params.targetGroupNetworkID != "db-network" {
+		t.Errorf("profile should set the listener subnet and TG network, got %+v", params)
+	}
+	if params.healthcheckIntervalSeconds != 5 || params.healthcheckHealthyThreshold != 3 {
+		t.Errorf("annotations should override profile health check defaults, got %+v", params)
+	}
+	if params.labels["team"] != "db" {
+		t.Errorf("profile labels should be set, got %v", params.labels)
+	}
+
+	class = loadBalancerClassNLBExternal
+	params, err = yc.getLoadBalancerParameters(&v1.Service{Spec: v1.ServiceSpec{LoadBalancerClass: &class}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.internal || params.targetGroupNetworkID != "default-network" {
+		t.Errorf("built-in external class should use the defaults, got %+v", params)
+	}
+
+	class = "metallb.io/metallb"
+	if _, err := yc.getLoadBalancerParameters(&v1.Service{Spec: v1.ServiceSpec{LoadBalancerClass: &class}}); err == nil {
+		t.Error("should return non-nil err on unknown class")
+	}
+
+	for _, invalid := range [][]loadBalancerClass{
+		{{Name: "no-prefix"}},
+		{{Name: "example.com/a"}, {Name: "example.com/a"}},
+		{{Name: "example.com/a", HealthCheck: loadBalancerClassHealthCheck{TimeoutSeconds: -1}}},
+		{{Name: "example.com/a", HealthCheck: loadBalancerClassHealthCheck{Protocol: "tcp", Path: "/ready"}}},
+		{{Name: "example.com/a", HealthCheck: loadBalancerClassHealthCheck{Host: "app.example.com"}}},
+	} {
+		if _, err := parseLoadBalancerClasses(invalid); err == nil {
+			t.Errorf("should return non-nil err for %+v", invalid)
+		}
+	}
+}
+
+func TestBuildHealthCheck(t *testing.T) {
+	yc := newTestCloud(nil)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		local       bool
+		expected    *loadbalancer.HealthCheck
+		wantErr     bool
+	}{
+		{
+			name: "default",
+			expected: &loadbalancer.HealthCheck{Options: &loadbalancer.HealthCheck_HttpOptions_{
+				HttpOptions: &loadbalancer.HealthCheck_HttpOptions{Port: lbNodesHealthCheckPort, Path: nodesHealthCheckPath},
+			}},
+		},
+		{
+			name:  "local traffic",
+			local: true,
+			expected: &loadbalancer.HealthCheck{Options: &loadbalancer.HealthCheck_HttpOptions_{
+				HttpOptions: &loadbalancer.HealthCheck_HttpOptions{Port: 31000, Path: nodesHealthCheckPath},
+			}},
+		},
+		{
+			name:        "HTTP path and port",
+			annotations: map[string]string{healthcheckPort: "8080", healthcheckPath: "/ready"},
+			local:       true,
+			expected: &loadbalancer.HealthCheck{Options: &loadbalancer.HealthCheck_HttpOptions_{
+				HttpOptions: &loadbalancer.HealthCheck_HttpOptions{Port: 8080, Path: "/ready"},
+			}},
+		},
+		{
+			name:        "TCP",
+			annotations: map[string]string{healthcheckProtocol: "tcp", healthcheckPort: "30080"},
+			expected: &loadbalancer.HealthCheck{Options: &loadbalancer.HealthCheck_TcpOptions_{
+				TcpOptions: &loadbalancer.HealthCheck_TcpOptions{Port: 30080},
+			}},
+		},
+		{
+			name:        "TCP with local traffic",
+			annotations: map[string]string{healthcheckProtocol: "tcp", healthcheckPort: "30080"},
+			local:       true,
+			expected: &loadbalancer.HealthCheck{Options: &loadbalancer.HealthCheck_HttpOptions_{
+				HttpOptions: &loadbalancer.HealthCheck_HttpOptions{Port: 31000, Path: nodesHealthCheckPath},
+			}},
+		},
+		{
+			name:        "TCP with path",
+			annotations: map[string]string{healthcheckProtocol: "TCP", healthcheckPath: "/ready"},
+			wantErr:     true,
+		},
+		{
+			name:        "unsupported protocol",
+			annotations: map[string]string{healthcheckProtocol: "grpc"},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid port",
+			annotations: map[string]string{healthcheckPort: "70000"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer}}
+			service.Annotations = tt.annotations
+			if tt.local {
+				service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyLocal
+				service.Spec.HealthCheckNodePort = 31000
+			}
+
+			params, err := yc.getLoadBalancerParameters(service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			healthCheck := buildHealthCheck(service, params)
+			if !reflect.DeepEqual(healthCheck.Options, tt.expected.Options) {
+				t.Errorf("expected options %v, got %v", tt.expected.Options, healthCheck.Options)
+			}
+		})
+	}
+}
+
+func TestBuildListenerSpecs(t *testing.T) {
+	service := &v1.Service{Spec: v1.ServiceSpec{
+		Type:       v1.ServiceTypeLoadBalancer,
+		IPFamilies: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+		Ports: []v1.ServicePort{
+			{Name: "http", Port: 80, NodePort: 30080, Protocol: v1.ProtocolTCP},
+			{Port: 53, NodePort: 30053, Protocol: v1.ProtocolUDP},
+		},
+	}}
+
+	specs, err := buildListenerSpecs(service, loadBalancerParameters{listenerAddressIPv6: "2a02:6b8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type listener struct {
+		name      string
+		address   string
+		ipVersion loadbalancer.IpVersion
+	}
+	var actual []listener
+	for _, spec := range specs {
+		external := spec.GetExternalAddressSpec()
+		actual = append(actual, listener{spec.Name, external.GetAddress(), external.GetIpVersion()})
+	}
+	expected := []listener{
+		{"http", "", loadbalancer.IpVersion_IPV4},
+		{"listener-1", "", loadbalancer.IpVersion_IPV4},
+		{"http-ipv6", "2a02:6b8::1", loadbalancer.IpVersion_IPV6},
+		{"listener-1-ipv6", "2a02:6b8::1", loadbalancer.IpVersion_IPV6},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	if _, err := buildListenerSpecs(service, loadBalancerParameters{listenerAddressIPv6: "2a02:6b8::1"}); err == nil {
+		t.Error("should return non-nil err for an IPv6 address of a single-stack IPv4 Service")
+	}
+
+	service.Annotations = map[string]string{listenerAddressIPv6: "1.2.3.4"}
+	if _, err := newTestCloud(nil).getLoadBalancerParameters(service); err == nil {
+		t.Error("should return non-nil err for an IPv4 address in the IPv6 annotation")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"sync"
 
@@ -14,15 +15,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
-	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
 
 	corev1listers "k8s.io/client-go/listers/core/v1"
 
 	mapset "github.com/deckarep/golang-set"
 
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type NodeTargetGroupSyncer struct {
@@ -30,7 +31,9 @@ type NodeTargetGroupSyncer struct {
 	cloud *Cloud
 
 	lastVisitedNodes mapset.Set
-	serviceLister    corev1listers.ServiceLister
+	// lastIPv6TGs are the target groups the IPv6 addresses of the Nodes were added to
+	lastIPv6TGs   sets.Set[string]
+	serviceLister corev1listers.ServiceLister
 
 	tgSyncLock sync.Mutex
 }
@@ -46,7 +49,7 @@ func (ntgs *NodeTargetGroupSyncer) SyncTGs(ctx context.Context, nodes []*corev1.
 
 	var activeLoadBalancerServicesExist bool
 	for _, service := range services {
-		if service.Spec.Type == corev1.ServiceTypeLoadBalancer && service.DeletionTimestamp == nil {
+		if ntgs.cloud.managesLoadBalancer(service) && service.DeletionTimestamp == nil {
 			activeLoadBalancerServicesExist = true
 			break
 		}
@@ -67,9 +70,16 @@ func (ntgs *NodeTargetGroupSyncer) SyncTGs(ctx context.Context, nodes []*corev1.
 
 type tgNameToTargetMap map[string][]*loadbalancer.Target
 
+// fromNodeToInterfaceSlice identifies Nodes by both the name and the ProviderID,
+// so that a Node re-registered for a recreated instance triggers a synchronization.
+// Preempted Nodes are told apart as well, since they are excluded from target groups.
 func fromNodeToInterfaceSlice(nodes []*corev1.Node) (ret []interface{}) {
 	for _, node := range nodes {
-		ret = append(ret, node.Name)
+		key := node.Name + "/" + node.Spec.ProviderID
+		if hasPreemptedTaint(node) {
+			key += "/preempted"
+		}
+		ret = append(ret, key)
 	}
 
 	return
@@ -94,6 +104,7 @@ func (ntgs *NodeTargetGroupSyncer) cleanUpTargetGroups(ctx context.Context) erro
 	}
 
 	ntgs.lastVisitedNodes.Clear()
+	ntgs.lastIPv6TGs = nil
 
 	return nil
 }
@@ -109,12 +120,17 @@ func (ntgs *NodeTargetGroupSyncer) synchronizeNodesWithTargetGroups(ctx context.
 		return nil
 	}
 
+	ipv6TGs, err := ntgs.ipv6TargetGroups()
+	if err != nil {
+		return err
+	}
+
 	newSet := mapset.NewSetFromSlice(fromNodeToInterfaceSlice(nodes))
-	if ntgs.lastVisitedNodes.Equal(newSet) {
+	if ntgs.lastVisitedNodes.Equal(newSet) && ntgs.lastIPv6TGs.Equal(ipv6TGs) {
 		return nil
 	}
 
-	// TODO: speed up by not performing individual lookups
+	// lookups are served by the instance cache when it is enabled
 	var instances []*instanceWithNodeInfo
 	for _, node := range nodes {
 		if node.Spec.ProviderID == "" {
@@ -126,17 +142,26 @@ func (ntgs *NodeTargetGroupSyncer) synchronizeNodesWithTargetGroups(ctx context.
 			continue
 		}
 
-		nodeName := MapNodeNameToInstanceName(types.NodeName(node.Name))
-		log.Printf("Finding Instance by Folder %q and Name %q", ntgs.cloud.config.FolderID, nodeName)
-		instance, err := ntgs.cloud.yandexService.ComputeSvc.FindInstanceByName(ctx, nodeName)
-		if err != nil || instance == nil {
-			return fmt.Errorf("failed to find Instance by its name: %s", err)
+		if hasPreemptedTaint(node) {
+			log.Printf("node %s is being preempted, skipping", node.Name)
+			continue
+		}
+
+		log.Printf("Finding Instance for Node %q", node.Name)
+		instance, err := ntgs.cloud.getInstanceByNode(ctx, node)
+		if err == cloudprovider.InstanceNotFound {
+			// the instance is gone or has been recreated, its stale address must not stay in the target groups
+			log.Printf("Instance of Node %q no longer exists, skipping", node.Name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find Instance for Node %q: %s", node.Name, err)
 		}
 
 		instances = append(instances, &instanceWithNodeInfo{Instance: instance, Node: node})
 	}
 
-	mapping, err := ntgs.constructTgNameToTargetMap(ctx, instances)
+	mapping, err := ntgs.constructTgNameToTargetMap(ctx, instances, ipv6TGs)
 	if err != nil {
 		return fmt.Errorf("failed to construct tgNameToTargetMap: %s", err)
 	}
@@ -149,29 +174,120 @@ func (ntgs *NodeTargetGroupSyncer) synchronizeNodesWithTargetGroups(ctx context.
 	}
 
 	ntgs.lastVisitedNodes = newSet
+	ntgs.lastIPv6TGs = ipv6TGs
+
+	return ntgs.synchronizeALBTargetGroups(ctx)
+}
+
+// SyncALBTargetGroups copies the targets of the network load balancer target groups
+// to the target groups of Application Load Balancers.
+func (ntgs *NodeTargetGroupSyncer) SyncALBTargetGroups(ctx context.Context) error {
+	ntgs.tgSyncLock.Lock()
+	defer ntgs.tgSyncLock.Unlock()
+
+	return ntgs.synchronizeALBTargetGroups(ctx)
+}
+
+func (ntgs *NodeTargetGroupSyncer) synchronizeALBTargetGroups(ctx context.Context) error {
+	services, err := ntgs.serviceLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list Services from an internal Indexer: %s", err)
+	}
+
+	for _, service := range services {
+		if !ntgs.cloud.managesLoadBalancer(service) || service.DeletionTimestamp != nil {
+			continue
+		}
+		if lbType, err := ntgs.cloud.loadBalancerType(service); err != nil || lbType != loadBalancerTypeALB {
+			continue
+		}
+
+		lbParams, err := ntgs.cloud.getLoadBalancerParameters(service)
+		if err != nil {
+			log.Printf("Skipping ALB target group of Service %s/%s: %s", service.Namespace, service.Name, err)
+			continue
+		}
+
+		tgName := lbParams.targetGroupNamePrefix + ntgs.cloud.config.ClusterName + lbParams.targetGroupNetworkID
+		tg, err := ntgs.cloud.yandexService.LbSvc.GetTgByName(ctx, tgName)
+		if err != nil {
+			return err
+		}
+		if tg == nil {
+			continue
+		}
+
+		err = ntgs.cloud.yandexService.AlbSvc.UpdateTGTargets(ctx, defaultLoadBalancerName(service), albTargets(tg.Targets))
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-func (ntgs *NodeTargetGroupSyncer) constructTgNameToTargetMap(ctx context.Context, instances []*instanceWithNodeInfo) (tgNameToTargetMap, error) {
+// ipv6TargetGroups returns the target groups of the network load balancers of IPv6 Services.
+func (ntgs *NodeTargetGroupSyncer) ipv6TargetGroups() (sets.Set[string], error) {
+	services, err := ntgs.serviceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Services from an internal Indexer: %s", err)
+	}
+
+	ret := sets.New[string]()
+	for _, service := range services {
+		if !ntgs.cloud.managesLoadBalancer(service) || service.DeletionTimestamp != nil ||
+			!slices.Contains(serviceIPFamilies(service), corev1.IPv6Protocol) {
+			continue
+		}
+		// the ALB listeners are IPv4 only
+		if lbType, err := ntgs.cloud.loadBalancerType(service); err != nil || lbType != loadBalancerTypeNLB {
+			continue
+		}
+
+		lbParams, err := ntgs.cloud.getLoadBalancerParameters(service)
+		if err != nil {
+			continue
+		}
+		ret.Insert(lbParams.targetGroupNamePrefix + ntgs.cloud.config.ClusterName + lbParams.targetGroupNetworkID)
+	}
+
+	return ret, nil
+}
+
+func (ntgs *NodeTargetGroupSyncer) constructTgNameToTargetMap(ctx context.Context, instances []*instanceWithNodeInfo, ipv6TGs sets.Set[string]) (tgNameToTargetMap, error) {
 	mapping := make(tgNameToTargetMap)
 
-	// TODO: Implement simple caching mechanism for subnet-VPC membership lookups
 	for _, instance := range instances {
 		for _, iface := range instance.Instance.NetworkInterfaces {
-			subnetInfo, err := ntgs.cloud.yandexService.VPCSvc.SubnetSvc.Get(ctx, &vpc.GetSubnetRequest{SubnetId: iface.SubnetId})
+			v4Address, v6Address := iface.GetPrimaryV4Address().GetAddress(), iface.GetPrimaryV6Address().GetAddress()
+			if v4Address == "" && v6Address == "" {
+				continue
+			}
+
+			subnetInfo, err := ntgs.cloud.yandexService.VPCSvc.GetSubnet(ctx, iface.SubnetId)
 			if err != nil {
 				return nil, errors.WithStack(err)
 			}
 
-			key := ntgs.cloud.config.ClusterName + subnetInfo.NetworkId
+			key := ntgs.cloud.config.ClusterName + subnetInfo.NetworkID
 			if v, ok := instance.Node.Annotations[customTargetGroupNamePrefixAnnotation]; ok {
 				key = truncateAnnotationValue(v) + key
 			}
-			mapping[key] = append(mapping[key], &loadbalancer.Target{
-				SubnetId: iface.SubnetId,
-				Address:  iface.PrimaryV4Address.Address,
-			})
+
+			addresses := make([]string, 0, 2)
+			if v4Address != "" {
+				addresses = append(addresses, v4Address)
+			}
+			// IPv6 targets serve the IPv6 listeners of dual-stack Services
+			if v6Address != "" && ipv6TGs.Has(key) {
+				addresses = append(addresses, v6Address)
+			}
+			for _, address := range addresses {
+				mapping[key] = append(mapping[key], &loadbalancer.Target{
+					SubnetId: iface.SubnetId,
+					Address:  address,
+				})
+			}
 		}
 	}
 
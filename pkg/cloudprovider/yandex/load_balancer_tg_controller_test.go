@@ -0,0 +1,78 @@
+package yandex
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+func TestConstructTgNameToTargetMapIPv6(t *testing.T) {
+	yc := newTestCloud(nil)
+	yc.config.ClusterName = "cluster"
+	yc.config.lbTgNetworkID = "network"
+	yc.yandexService.VPCSvc = yapi.NewVPCService(nil, &fakeSubnetService{networkID: "network"}, nil, nil, &yapi.CloudContext{})
+
+	services := []*v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ipv4"},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, IPFamilies: []v1.IPFamily{v1.IPv4Protocol}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dual-stack", Annotations: map[string]string{customTargetGroupNamePrefixAnnotation: "db"}},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, IPFamilies: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}},
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, service := range services {
+		if err := indexer.Add(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	syncer := &NodeTargetGroupSyncer{cloud: yc, serviceLister: listersv1.NewServiceLister(indexer)}
+
+	newInstance := func(node *v1.Node, v4Address, v6Address string) *instanceWithNodeInfo {
+		return &instanceWithNodeInfo{Node: node, Instance: &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{{
+			SubnetId:         "subnet",
+			PrimaryV4Address: &compute.PrimaryAddress{Address: v4Address},
+			PrimaryV6Address: &compute.PrimaryAddress{Address: v6Address},
+		}}}}
+	}
+	instances := []*instanceWithNodeInfo{
+		newInstance(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "vm1"}}, "10.0.0.1", "2001:db8::1"),
+		newInstance(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "vm2", Annotations: map[string]string{customTargetGroupNamePrefixAnnotation: "db"}}}, "10.0.0.2", "2001:db8::2"),
+	}
+
+	ipv6TGs, err := syncer.ipv6TargetGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := syncer.constructTgNameToTargetMap(context.Background(), instances, ipv6TGs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"clusternetwork":   {"10.0.0.1"},
+		"dbclusternetwork": {"10.0.0.2", "2001:db8::2"},
+	}
+	if len(mapping) != len(expected) {
+		t.Fatalf("expected target groups %v, got %v", expected, mapping)
+	}
+	for tgName, addresses := range expected {
+		var actual []string
+		for _, target := range mapping[tgName] {
+			actual = append(actual, target.Address)
+		}
+		if !slices.Equal(actual, addresses) {
+			t.Errorf("expected %s targets %v, got %v", tgName, addresses, actual)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package yandex
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+const metricsSubsystem = "yandex_ccm"
+
+var (
+	credentialsRotations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "credentials_rotations_total",
+			Help:           "Number of service account key rotations picked up from the key file, partitioned by result.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics registers the provider metrics in the legacy registry served by the CCM on /metrics.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(credentialsRotations)
+		yapi.RegisterMetrics()
+	})
+}
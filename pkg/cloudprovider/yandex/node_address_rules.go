@@ -0,0 +1,214 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+const (
+	// nodeAnnotationNetworkInterfaces lists the addresses of all instance network interfaces along with the rules that matched them.
+	nodeAnnotationNetworkInterfaces = "yandex.cloud/network-interfaces"
+	// nodeAnnotationAddressRules lists the address rules in effect, matched rules are referred to by their index.
+	nodeAnnotationAddressRules = "yandex.cloud/address-rules"
+)
+
+// addressRuleSkip is the address rule type that excludes matching addresses from the Node addresses.
+const addressRuleSkip = "Skip"
+
+// addressRule selects the type of the primary addresses of instance network interfaces.
+// All the conditions that are set must match, a rule without conditions matches all addresses.
+type addressRule struct {
+	NetworkID      string            `json:"networkID,omitempty"`
+	SubnetID       string            `json:"subnetID,omitempty"`
+	InterfaceIndex *int              `json:"interfaceIndex,omitempty"`
+	CIDR           string            `json:"cidr,omitempty"`
+	SubnetLabels   map[string]string `json:"subnetLabels,omitempty"`
+
+	// Type is InternalIP, ExternalIP or Skip.
+	Type string `json:"type"`
+
+	cidr *net.IPNet
+	// legacy rules are converted from the network ID lists, which are applied independently of each other
+	// and keep one-to-one NAT addresses
+	legacy bool
+}
+
+// interfaceAddress is a primary address of an instance network interface and the rule that matched it.
+type interfaceAddress struct {
+	InterfaceIndex string `json:"interfaceIndex"`
+	SubnetID       string `json:"subnetID"`
+	NetworkID      string `json:"networkID,omitempty"`
+	Address        string `json:"address"`
+	Type           string `json:"type"`
+	// Rule is the index of the matching rule, -1 if no rule matches and the address is skipped
+	Rule int `json:"rule"`
+}
+
+// parseAddressRules validates the rules, legacy network ID lists are used only if there are no rules.
+func parseAddressRules(rules []addressRule, internalNetworkIDs, externalNetworkIDs []string) ([]addressRule, error) {
+	if len(rules) == 0 {
+		rules = legacyAddressRules(internalNetworkIDs, externalNetworkIDs)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		switch v1.NodeAddressType(rule.Type) {
+		case v1.NodeInternalIP, v1.NodeExternalIP, addressRuleSkip:
+		default:
+			return nil, fmt.Errorf("address rule %d: unknown type %q, expected one of InternalIP, ExternalIP and Skip", i, rule.Type)
+		}
+
+		if len(rule.CIDR) > 0 {
+			_, cidr, err := net.ParseCIDR(rule.CIDR)
+			if err != nil {
+				return nil, errors.Wrapf(err, "address rule %d: malformed CIDR", i)
+			}
+			rule.cidr = cidr
+		}
+	}
+
+	return rules, nil
+}
+
+// legacyAddressRules converts internal and external network ID lists to address rules.
+// Without internal networks, the first interface is considered internal.
+// The rules keep the order of the lists, so that the address rules annotation is stable.
+func legacyAddressRules(internalNetworkIDs, externalNetworkIDs []string) []addressRule {
+	var rules []addressRule
+
+	internalNetworkIDs = uniqueNonEmpty(internalNetworkIDs)
+	if len(internalNetworkIDs) == 0 {
+		firstInterface := 0
+		rules = append(rules, addressRule{InterfaceIndex: &firstInterface, Type: string(v1.NodeInternalIP), legacy: true})
+	}
+	for _, networkID := range internalNetworkIDs {
+		rules = append(rules, addressRule{NetworkID: networkID, Type: string(v1.NodeInternalIP), legacy: true})
+	}
+	for _, networkID := range uniqueNonEmpty(externalNetworkIDs) {
+		rules = append(rules, addressRule{NetworkID: networkID, Type: string(v1.NodeExternalIP), legacy: true})
+	}
+
+	return rules
+}
+
+// uniqueNonEmpty returns the trimmed non-empty values in their original order without duplicates.
+func uniqueNonEmpty(values []string) []string {
+	var ret []string
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if _, ok := seen[value]; ok || len(value) == 0 {
+			continue
+		}
+		seen[value] = struct{}{}
+		ret = append(ret, value)
+	}
+
+	return ret
+}
+
+func (rule *addressRule) matches(iface *compute.NetworkInterface, ifaceIndex string, subnetNetworkID string, subnetLabels map[string]string, address string) bool {
+	if len(rule.NetworkID) > 0 && rule.NetworkID != subnetNetworkID {
+		return false
+	}
+	if len(rule.SubnetID) > 0 && rule.SubnetID != iface.SubnetId {
+		return false
+	}
+	if rule.InterfaceIndex != nil && strconv.Itoa(*rule.InterfaceIndex) != ifaceIndex {
+		return false
+	}
+	if rule.cidr != nil && !rule.cidr.Contains(net.ParseIP(address)) {
+		return false
+	}
+	if len(rule.SubnetLabels) > 0 && !labels.SelectorFromSet(rule.SubnetLabels).Matches(labels.Set(subnetLabels)) {
+		return false
+	}
+
+	return true
+}
+
+// hasExternalAddressRules tells whether ExternalIPs are selected by rules rather than taken from one-to-one NAT.
+// One-to-one NAT addresses are reported along with the external networks of the legacy rules.
+func (yc *Cloud) hasExternalAddressRules() bool {
+	for _, rule := range yc.config.AddressRules {
+		if rule.Type == string(v1.NodeExternalIP) && !rule.legacy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// needsSubnet tells whether the rule can only be evaluated with the subnet metadata.
+func (rule *addressRule) needsSubnet() bool {
+	return len(rule.NetworkID) > 0 || len(rule.SubnetLabels) > 0
+}
+
+// classifyInterfaceAddresses applies the address rules to the primary addresses of all instance network interfaces.
+// Subnets are only looked up if the rules refer to networks or subnet labels.
+// An address matched by several legacy rules is listed once per rule, e.g. as both an InternalIP and an ExternalIP.
+func (yc *Cloud) classifyInterfaceAddresses(ctx context.Context, instance *compute.Instance) ([]interfaceAddress, error) {
+	var needsSubnet bool
+	for i := range yc.config.AddressRules {
+		needsSubnet = needsSubnet || yc.config.AddressRules[i].needsSubnet()
+	}
+
+	var addresses []interfaceAddress
+	for position, iface := range instance.NetworkInterfaces {
+		ifaceIndex := iface.Index
+		if len(ifaceIndex) == 0 {
+			ifaceIndex = strconv.Itoa(position)
+		}
+
+		subnet := &yapi.Subnet{ID: iface.SubnetId}
+		if needsSubnet {
+			var err error
+			subnet, err = yc.yandexService.VPCSvc.GetSubnet(ctx, iface.SubnetId)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+
+		for _, address := range interfacePrimaryAddresses(iface) {
+			classified := interfaceAddress{
+				InterfaceIndex: ifaceIndex,
+				SubnetID:       iface.SubnetId,
+				NetworkID:      subnet.NetworkID,
+				Address:        address,
+				Type:           addressRuleSkip,
+				Rule:           -1,
+			}
+			var matched bool
+			for i := range yc.config.AddressRules {
+				rule := &yc.config.AddressRules[i]
+				if !rule.matches(iface, ifaceIndex, subnet.NetworkID, subnet.Labels, address) {
+					continue
+				}
+
+				classified.Type = rule.Type
+				classified.Rule = i
+				addresses = append(addresses, classified)
+				matched = true
+				if !rule.legacy {
+					break
+				}
+			}
+
+			if !matched {
+				addresses = append(addresses, classified)
+			}
+		}
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,374 @@
+package yandex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// Labels set on Nodes from the attributes of their instances.
+const (
+	nodeLabelPreemptible      = "yandex.cloud/preemptible"
+	nodeLabelPlatformID       = "yandex.cloud/platform-id"
+	nodeLabelGPUs             = "yandex.cloud/gpus"
+	nodeLabelPlacementGroupID = "yandex.cloud/placement-group-id"
+	nodeLabelHostGroupID      = "yandex.cloud/host-group-id"
+	nodeLabelHostID           = "yandex.cloud/host-id"
+	nodeLabelInstanceGroupID  = "yandex.cloud/instance-group-id"
+
+	defaultInstanceLabelPrefix = "instance.yandex.cloud/"
+
+	defaultNodeAttributesSyncInterval = 5 * time.Minute
+)
+
+var instanceAttributeNodeLabels = []string{
+	nodeLabelPreemptible,
+	nodeLabelPlatformID,
+	nodeLabelGPUs,
+	nodeLabelPlacementGroupID,
+	nodeLabelHostGroupID,
+	nodeLabelHostID,
+	nodeLabelInstanceGroupID,
+}
+
+// nodeTaint is a taint applied to the Nodes whose instance attribute labels match MatchLabels.
+type nodeTaint struct {
+	Key    string         `json:"key"`
+	Value  string         `json:"value,omitempty"`
+	Effect v1.TaintEffect `json:"effect"`
+
+	// MatchLabels selects Nodes by the labels derived from their instances, e.g. "yandex.cloud/preemptible: true".
+	// All Nodes are selected if it is empty.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+func validateNodeTaints(taints []nodeTaint) error {
+	for _, taint := range taints {
+		if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+			return fmt.Errorf("invalid Node taint key %q: %s", taint.Key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+			return fmt.Errorf("invalid Node taint value %q: %s", taint.Value, strings.Join(errs, ", "))
+		}
+		switch taint.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("invalid Node taint %q effect %q", taint.Key, taint.Effect)
+		}
+	}
+
+	return nil
+}
+
+// instanceNodeLabels returns the Node labels derived from the instance attributes and the allowlisted instance labels.
+func (yc *Cloud) instanceNodeLabels(ctx context.Context, instance *compute.Instance) map[string]string {
+	nodeLabels := map[string]string{
+		nodeLabelPreemptible: strconv.FormatBool(instance.GetSchedulingPolicy().GetPreemptible()),
+		nodeLabelPlatformID:  instance.PlatformId,
+		nodeLabelGPUs:        strconv.FormatInt(instance.GetResources().GetGpus(), 10),
+	}
+
+	if placementGroupID := instance.GetPlacementPolicy().GetPlacementGroupId(); len(placementGroupID) > 0 {
+		nodeLabels[nodeLabelPlacementGroupID] = placementGroupID
+	}
+	if len(instance.HostGroupId) > 0 {
+		nodeLabels[nodeLabelHostGroupID] = instance.HostGroupId
+	}
+	if len(instance.HostId) > 0 {
+		nodeLabels[nodeLabelHostID] = instance.HostId
+	}
+
+	if igs := yc.yandexService.InstanceGroupSvc; igs != nil {
+		instanceGroupID, err := igs.GetInstanceGroupID(ctx, instance.Id)
+		if err != nil {
+			klog.Errorf("failed to find the instance group of Instance %q: %s", instance.Name, err)
+		} else if len(instanceGroupID) > 0 {
+			nodeLabels[nodeLabelInstanceGroupID] = instanceGroupID
+		}
+	}
+
+	for _, key := range yc.config.NodeInstanceLabels {
+		value, ok := instance.Labels[key]
+		if !ok {
+			continue
+		}
+
+		nodeLabel := yc.config.NodeInstanceLabelPrefix + key
+		if errs := validation.IsQualifiedName(nodeLabel); len(errs) > 0 {
+			klog.Errorf("skipping Instance %q label %q: invalid Node label %q: %s", instance.Name, key, nodeLabel, strings.Join(errs, ", "))
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			klog.Errorf("skipping Instance %q label %q: invalid Node label value %q: %s", instance.Name, key, value, strings.Join(errs, ", "))
+			continue
+		}
+
+		nodeLabels[nodeLabel] = value
+	}
+
+	return nodeLabels
+}
+
+// nodeTaints returns the configured taints matching the instance attribute labels.
+func (yc *Cloud) nodeTaints(nodeLabels map[string]string) []v1.Taint {
+	var taints []v1.Taint
+	for _, taint := range yc.config.NodeTaints {
+		if !labels.SelectorFromSet(taint.MatchLabels).Matches(labels.Set(nodeLabels)) {
+			continue
+		}
+
+		taints = append(taints, v1.Taint{Key: taint.Key, Value: taint.Value, Effect: taint.Effect})
+	}
+
+	return taints
+}
+
+// isManagedNodeLabel tells the labels owned by the CCM, which are removed once they no longer apply.
+func (yc *Cloud) isManagedNodeLabel(key string) bool {
+	for _, managed := range instanceAttributeNodeLabels {
+		if key == managed {
+			return true
+		}
+	}
+
+	prefix := yc.config.NodeInstanceLabelPrefix
+	return len(prefix) > 0 && strings.HasPrefix(key, prefix)
+}
+
+func (yc *Cloud) isManagedNodeTaint(taint v1.Taint) bool {
+	for _, managed := range yc.config.NodeTaints {
+		if taint.Key == managed.Key && taint.Effect == managed.Effect {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyNodeAttributes updates the labels, annotations and taints of the Node in place and reports whether anything has changed.
+func (yc *Cloud) applyNodeAttributes(node *v1.Node, nodeLabels, annotations map[string]string, taints []v1.Taint) bool {
+	var changed bool
+
+	if len(annotations) > 0 && node.Annotations == nil {
+		node.Annotations = make(map[string]string, len(annotations))
+	}
+	for key, value := range annotations {
+		if current, ok := node.Annotations[key]; !ok || current != value {
+			node.Annotations[key] = value
+			changed = true
+		}
+	}
+
+	if node.Labels == nil {
+		node.Labels = make(map[string]string, len(nodeLabels))
+	}
+	for key := range node.Labels {
+		if _, ok := nodeLabels[key]; !ok && yc.isManagedNodeLabel(key) {
+			delete(node.Labels, key)
+			changed = true
+		}
+	}
+	for key, value := range nodeLabels {
+		if current, ok := node.Labels[key]; !ok || current != value {
+			node.Labels[key] = value
+			changed = true
+		}
+	}
+
+	var newTaints []v1.Taint
+	for _, taint := range node.Spec.Taints {
+		if !yc.isManagedNodeTaint(taint) {
+			newTaints = append(newTaints, taint)
+		}
+	}
+	newTaints = append(newTaints, taints...)
+	if !taintsAreEqual(node.Spec.Taints, newTaints) {
+		node.Spec.Taints = newTaints
+		changed = true
+	}
+
+	return changed
+}
+
+func taintsAreEqual(a, b []v1.Taint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, taint := range a {
+		var found bool
+		for _, other := range b {
+			if taint.Key == other.Key && taint.Value == other.Value && taint.Effect == other.Effect {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodeAnnotations returns the Node annotations describing the instance's network interfaces and the address rules in effect.
+func (yc *Cloud) nodeAnnotations(ctx context.Context, instance *compute.Instance) (map[string]string, error) {
+	interfaceAddresses, err := yc.classifyInterfaceAddresses(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	networkInterfaces, err := json.Marshal(interfaceAddresses)
+	if err != nil {
+		return nil, err
+	}
+	addressRules, err := json.Marshal(yc.config.AddressRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		nodeAnnotationNetworkInterfaces: string(networkInterfaces),
+		nodeAnnotationAddressRules:      string(addressRules),
+	}, nil
+}
+
+// NodeAttributesSyncer keeps the labels, annotations and taints of Nodes in sync with the attributes of their instances.
+// The node controller sets the labels only once, when a Node is initialized.
+// New Nodes are synchronized as soon as they are added, so that they don't stay schedulable without their taints.
+type NodeAttributesSyncer struct {
+	cloud      *Cloud
+	client     kubernetes.Interface
+	nodeLister listersv1.NodeLister
+
+	queue workqueue.TypedRateLimitingInterface[string]
+}
+
+func newNodeAttributesSyncer(cloud *Cloud, client kubernetes.Interface, nodeInformer coreinformers.NodeInformer) *NodeAttributesSyncer {
+	nas := &NodeAttributesSyncer{
+		cloud:      cloud,
+		client:     client,
+		nodeLister: nodeInformer.Lister(),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "node-attributes"},
+		),
+	}
+
+	_, _ = nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if node, ok := obj.(*v1.Node); ok {
+				nas.queue.Add(node.Name)
+			}
+		},
+	})
+
+	return nas
+}
+
+// Run synchronizes the added Nodes and all Nodes every interval until stop is closed.
+func (nas *NodeAttributesSyncer) Run(stop <-chan struct{}, interval time.Duration) {
+	defer nas.queue.ShutDown()
+
+	go wait.Until(func() {
+		for nas.processNextItem(interval) {
+		}
+	}, time.Second, stop)
+
+	wait.Until(func() {
+		nodes, err := nas.nodeLister.List(labels.Everything())
+		if err != nil {
+			klog.Errorf("failed to list Nodes: %s", err)
+			return
+		}
+
+		for _, node := range nodes {
+			nas.queue.Add(node.Name)
+		}
+	}, interval, stop)
+}
+
+func (nas *NodeAttributesSyncer) processNextItem(timeout time.Duration) bool {
+	name, quit := nas.queue.Get()
+	if quit {
+		return false
+	}
+	defer nas.queue.Done(name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	node, err := nas.nodeLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		nas.queue.Forget(name)
+		return true
+	}
+	if err == nil {
+		err = nas.syncNode(ctx, node)
+	}
+	if err != nil {
+		klog.Errorf("failed to sync labels and taints of Node %q: %s", name, err)
+		nas.queue.AddRateLimited(name)
+		return true
+	}
+	nas.queue.Forget(name)
+
+	return true
+}
+
+func (nas *NodeAttributesSyncer) syncNode(ctx context.Context, node *v1.Node) error {
+	instance, err := nas.cloud.getInstanceByNode(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	nodeLabels := nas.cloud.instanceNodeLabels(ctx, instance)
+	taints := nas.cloud.nodeTaints(nodeLabels)
+
+	// the labels and taints don't depend on the annotations, so they are applied anyway
+	annotations, err := nas.cloud.nodeAnnotations(ctx, instance)
+	if err != nil {
+		klog.Errorf("failed to build the annotations of Node %q: %s", node.Name, err)
+	}
+
+	if !nas.cloud.applyNodeAttributes(node.DeepCopy(), nodeLabels, annotations, taints) {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := nas.client.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		updated := current.DeepCopy()
+		if !nas.cloud.applyNodeAttributes(updated, nodeLabels, annotations, taints) {
+			return nil
+		}
+
+		_, err = nas.client.CoreV1().Nodes().Update(ctx, updated, metav1.UpdateOptions{})
+		if err == nil {
+			klog.Infof("updated labels and taints of Node %q", node.Name)
+		}
+
+		return err
+	})
+}
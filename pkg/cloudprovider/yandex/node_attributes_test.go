@@ -0,0 +1,142 @@
+package yandex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+func TestNodeAttributesSync(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.PlatformId = "gpu-standard-v3"
+	instance.Resources = &compute.Resources{Cores: 8, Memory: 96 << 30, CoreFraction: 100, Gpus: 1}
+	instance.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+	instance.PlacementPolicy = &compute.PlacementPolicy{PlacementGroupId: "pg1"}
+	instance.Labels = map[string]string{"pool": "batch", "owner": "team"}
+
+	yc := newTestCloud(&fakeInstanceService{instances: []*compute.Instance{instance}})
+	yc.config.NodeInstanceLabels = []string{"pool"}
+	yc.config.NodeInstanceLabelPrefix = defaultInstanceLabelPrefix
+	yc.config.NodeTaints = []nodeTaint{
+		{Key: "preemptible", Value: "true", Effect: v1.TaintEffectNoSchedule, MatchLabels: map[string]string{nodeLabelPreemptible: "true"}},
+		{Key: "gpu-only", Effect: v1.TaintEffectNoSchedule, MatchLabels: map[string]string{nodeLabelGPUs: "0"}},
+	}
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "vm1",
+			Labels: map[string]string{
+				"kubernetes.io/hostname":         "vm1",
+				nodeLabelHostGroupID:             "stale",
+				defaultInstanceLabelPrefix + "x": "stale",
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "yandex://id1",
+			Taints: []v1.Taint{
+				{Key: "dedicated", Value: "infra", Effect: v1.TaintEffectNoExecute},
+				{Key: "gpu-only", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(node)
+
+	syncer := &NodeAttributesSyncer{cloud: yc, client: client}
+	if err := syncer.syncNode(context.Background(), node); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := client.CoreV1().Nodes().Get(context.Background(), "vm1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLabels := map[string]string{
+		"kubernetes.io/hostname":            "vm1",
+		nodeLabelPreemptible:                "true",
+		nodeLabelPlatformID:                 "gpu-standard-v3",
+		nodeLabelGPUs:                       "1",
+		nodeLabelPlacementGroupID:           "pg1",
+		defaultInstanceLabelPrefix + "pool": "batch",
+	}
+	if !reflect.DeepEqual(updated.Labels, expectedLabels) {
+		t.Errorf("unexpected labels %v", updated.Labels)
+	}
+
+	expectedTaints := []v1.Taint{
+		{Key: "dedicated", Value: "infra", Effect: v1.TaintEffectNoExecute},
+		{Key: "preemptible", Value: "true", Effect: v1.TaintEffectNoSchedule},
+	}
+	if !reflect.DeepEqual(updated.Spec.Taints, expectedTaints) {
+		t.Errorf("unexpected taints %v", updated.Spec.Taints)
+	}
+
+	if updated.Annotations[nodeAnnotationNetworkInterfaces] != `[{"interfaceIndex":"0","subnetID":"subnet","address":"10.0.0.1","type":"InternalIP","rule":0}]` {
+		t.Errorf("unexpected network interfaces annotation %q", updated.Annotations[nodeAnnotationNetworkInterfaces])
+	}
+
+	annotations, err := yc.nodeAnnotations(context.Background(), instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if yc.applyNodeAttributes(updated, yc.instanceNodeLabels(context.Background(), instance), annotations, yc.nodeTaints(expectedLabels)) {
+		t.Error("synchronized Node should not be changed again")
+	}
+}
+
+func TestNodeAttributesSyncOnAdd(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+
+	yc := newTestCloud(&fakeInstanceService{instances: []*compute.Instance{instance}})
+	yc.config.NodeTaints = []nodeTaint{
+		{Key: "preemptible", Value: "true", Effect: v1.TaintEffectNoSchedule, MatchLabels: map[string]string{nodeLabelPreemptible: "true"}},
+	}
+	// the subnet lookup of the address rules fails, so the annotations can't be built
+	yc.config.AddressRules = []addressRule{{NetworkID: "network", Type: string(v1.NodeInternalIP)}}
+	yc.yandexService.VPCSvc = yapi.NewVPCService(nil, &fakeSubnetService{err: errors.New("unavailable")}, nil, nil, &yapi.CloudContext{})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "vm1"},
+		Spec:       v1.NodeSpec{ProviderID: "yandex://id1"},
+	}
+	client := fake.NewSimpleClientset(node)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	syncer := newNodeAttributesSyncer(yc, client, informerFactory.Core().V1().Nodes())
+	defer syncer.queue.ShutDown()
+	informerFactory.Start(stop)
+	informerFactory.WaitForCacheSync(stop)
+
+	if syncer.queue.Len() != 1 {
+		t.Fatalf("expected the added Node to be queued, queue length is %d", syncer.queue.Len())
+	}
+	syncer.processNextItem(time.Minute)
+
+	updated, err := client.CoreV1().Nodes().Get(context.Background(), "vm1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Labels[nodeLabelPreemptible] != "true" {
+		t.Errorf("unexpected labels %v", updated.Labels)
+	}
+	expectedTaints := []v1.Taint{{Key: "preemptible", Value: "true", Effect: v1.TaintEffectNoSchedule}}
+	if !reflect.DeepEqual(updated.Spec.Taints, expectedTaints) {
+		t.Errorf("unexpected taints %v", updated.Spec.Taints)
+	}
+	if _, ok := updated.Annotations[nodeAnnotationNetworkInterfaces]; ok {
+		t.Errorf("unexpected network interfaces annotation %q", updated.Annotations[nodeAnnotationNetworkInterfaces])
+	}
+}
@@ -0,0 +1,93 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+// nodeNameMapping is the strategy used to find the instance backing a Kubernetes Node by the Node's name.
+type nodeNameMapping string
+
+const (
+	// nodeNameMappingVMName expects Node names to be equal to VM names.
+	nodeNameMappingVMName nodeNameMapping = "VMName"
+	// nodeNameMappingHostname expects Node names to be equal to the host name part of the instance FQDN.
+	nodeNameMappingHostname nodeNameMapping = "Hostname"
+	// nodeNameMappingFQDN expects Node names to be equal to the instance FQDN.
+	nodeNameMappingFQDN nodeNameMapping = "FQDN"
+	// nodeNameMappingInstanceLabel expects the Node name to be stored in an instance label.
+	nodeNameMappingInstanceLabel nodeNameMapping = "InstanceLabel"
+	// nodeNameMappingProviderID uses the ProviderID of the Node object and falls back to VM names for Nodes without one.
+	nodeNameMappingProviderID nodeNameMapping = "ProviderID"
+)
+
+func parseNodeNameMapping(strategy, instanceLabel string) (nodeNameMapping, error) {
+	switch mapping := nodeNameMapping(strategy); mapping {
+	case "":
+		return nodeNameMappingVMName, nil
+	case nodeNameMappingVMName, nodeNameMappingHostname, nodeNameMappingFQDN, nodeNameMappingProviderID:
+		return mapping, nil
+	case nodeNameMappingInstanceLabel:
+		if len(instanceLabel) == 0 {
+			return "", fmt.Errorf("instance label is required for the %q node name mapping: set %q or \"nodeNameMapping.instanceLabel\" in the cloud config", mapping, envNodeNameLabel)
+		}
+		return mapping, nil
+	default:
+		return "", fmt.Errorf("unknown node name mapping %q", strategy)
+	}
+}
+
+func (yc *Cloud) getInstanceByNodeName(ctx context.Context, nodeName types.NodeName) (*compute.Instance, error) {
+	var (
+		instance *compute.Instance
+		err      error
+	)
+
+	name := string(nodeName)
+	computeSvc := yc.yandexService.ComputeSvc
+
+	switch yc.config.NodeNameMapping {
+	case nodeNameMappingHostname:
+		instance, err = computeSvc.FindInstance(ctx, fmt.Sprintf("the hostname %q", name), func(instance *compute.Instance) bool {
+			hostname, _, _ := strings.Cut(instance.Fqdn, ".")
+			return hostname == name
+		})
+	case nodeNameMappingFQDN:
+		instance, err = computeSvc.FindInstance(ctx, fmt.Sprintf("the FQDN %q", name), func(instance *compute.Instance) bool {
+			return instance.Fqdn == name
+		})
+	case nodeNameMappingInstanceLabel:
+		label := yc.config.NodeNameInstanceLabel
+		instance, err = computeSvc.FindInstance(ctx, fmt.Sprintf("the label %s=%q", label, name), func(instance *compute.Instance) bool {
+			return instance.Labels[label] == name
+		})
+	case nodeNameMappingProviderID:
+		if yc.nodeLister != nil {
+			node, err := yc.nodeLister.Get(name)
+			if err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			if node != nil && len(node.Spec.ProviderID) > 0 {
+				return yc.getInstanceByProviderID(ctx, node.Spec.ProviderID)
+			}
+		}
+
+		instance, err = computeSvc.FindInstanceByName(ctx, MapNodeNameToInstanceName(nodeName))
+	default:
+		instance, err = computeSvc.FindInstanceByName(ctx, MapNodeNameToInstanceName(nodeName))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		return nil, cloudprovider.InstanceNotFound
+	}
+
+	return instance, nil
+}
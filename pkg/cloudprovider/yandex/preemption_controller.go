@@ -0,0 +1,205 @@
+package yandex
+
+import (
+	"context"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+const (
+	// nodeTaintPreempted evicts the pods from the Nodes of preempted instances.
+	nodeTaintPreempted = "yandex.cloud/preempted"
+
+	// preemptible instances are stopped about 30 seconds after the preemption starts
+	defaultPreemptionPollInterval = 5 * time.Second
+)
+
+// preemptionSource returns the current state of the preemptible instances.
+type preemptionSource interface {
+	// Instances returns the instances found by the IDs, missing instances are left out.
+	Instances(ctx context.Context, instanceIDs []string) (map[string]*compute.Instance, error)
+}
+
+// computePreemptionSource polls the status of preemptible instances from the Compute API, bypassing the instance cache.
+// The instance folders are listed once per poll, so the number of API calls doesn't grow with the number of Nodes.
+type computePreemptionSource struct {
+	cloud *Cloud
+}
+
+func (s *computePreemptionSource) Instances(ctx context.Context, instanceIDs []string) (map[string]*compute.Instance, error) {
+	wanted := sets.New(instanceIDs...)
+
+	instances, err := s.cloud.yandexService.ComputeSvc.ListInstances(yapi.WithCacheBypass(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*compute.Instance, len(instanceIDs))
+	for _, instance := range instances {
+		if wanted.Has(instance.Id) {
+			ret[instance.Id] = instance
+		}
+	}
+
+	return ret, nil
+}
+
+// PreemptionController drains the Nodes of preempted instances before the instances are stopped:
+// it taints the Nodes and removes them from all target groups of the cluster.
+type PreemptionController struct {
+	cloud      *Cloud
+	client     kubernetes.Interface
+	nodeLister listersv1.NodeLister
+	source     preemptionSource
+}
+
+// Run checks preemptible instances every interval until stop is closed.
+func (pc *PreemptionController) Run(stop <-chan struct{}, interval time.Duration) {
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := pc.sync(ctx); err != nil {
+			klog.Errorf("failed to check preemptible instances: %s", err)
+		}
+	}, interval, stop)
+}
+
+func (pc *PreemptionController) sync(ctx context.Context) error {
+	nodes, err := pc.nodeLister.List(labels.SelectorFromSet(labels.Set{nodeLabelPreemptible: "true"}))
+	if err != nil {
+		return err
+	}
+
+	var instanceIDs []string
+	nodesByInstanceID := make(map[string]*v1.Node, len(nodes))
+	for _, node := range nodes {
+		instanceID, isID, err := ParseProviderID(node.Spec.ProviderID)
+		if err != nil || !isID {
+			continue
+		}
+
+		instanceIDs = append(instanceIDs, instanceID)
+		nodesByInstanceID[instanceID] = node
+	}
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+
+	instances, err := pc.source.Instances(ctx, instanceIDs)
+	if err != nil {
+		return err
+	}
+
+	for instanceID, instance := range instances {
+		node := nodesByInstanceID[instanceID]
+		if hasPreemptedTaint(node) {
+			pc.restoreNode(ctx, node, instance)
+			continue
+		}
+
+		if pc.cloud.instanceState(ctx, instance) != instanceStatePreempted {
+			continue
+		}
+		if err := pc.handlePreemption(ctx, node, instance); err != nil {
+			klog.Errorf("failed to drain Node %q of preempted Instance %q: %s", node.Name, instance.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (pc *PreemptionController) handlePreemption(ctx context.Context, node *v1.Node, instance *compute.Instance) error {
+	klog.Infof("Instance %q of Node %q is being preempted, draining the Node", instance.Name, node.Name)
+
+	if err := pc.updateTaint(ctx, node.Name, true); err != nil {
+		return err
+	}
+
+	var addresses []string
+	for _, iface := range instance.NetworkInterfaces {
+		addresses = append(addresses, interfacePrimaryAddresses(iface)...)
+	}
+	if err := pc.cloud.yandexService.LbSvc.RemoveAddressesFromTGs(ctx, pc.cloud.config.ClusterName, addresses); err != nil {
+		return err
+	}
+	if syncer := pc.cloud.nodeTargetGroupSyncer; syncer != nil {
+		if err := syncer.SyncALBTargetGroups(ctx); err != nil {
+			return err
+		}
+	}
+
+	if pc.cloud.recorder != nil {
+		pc.cloud.recorder.Eventf(node, v1.EventTypeWarning, "InstancePreempted",
+			"Instance %q is being preempted, the Node has been tainted and removed from load balancer target groups", instance.Name)
+	}
+
+	return nil
+}
+
+// restoreNode removes the taint once the preempted instance is running again.
+func (pc *PreemptionController) restoreNode(ctx context.Context, node *v1.Node, instance *compute.Instance) {
+	if instance.Status != compute.Instance_RUNNING {
+		return
+	}
+
+	if err := pc.updateTaint(ctx, node.Name, false); err != nil {
+		klog.Errorf("failed to remove the %s taint from Node %q: %s", nodeTaintPreempted, node.Name, err)
+		return
+	}
+	klog.Infof("Instance %q of Node %q is running again after preemption", instance.Name, node.Name)
+}
+
+func (pc *PreemptionController) updateTaint(ctx context.Context, nodeName string, present bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := pc.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if hasPreemptedTaint(node) == present {
+			return nil
+		}
+
+		updated := node.DeepCopy()
+		if present {
+			updated.Spec.Taints = append(updated.Spec.Taints, v1.Taint{
+				Key:       nodeTaintPreempted,
+				Effect:    v1.TaintEffectNoExecute,
+				TimeAdded: &metav1.Time{Time: time.Now()},
+			})
+		} else {
+			var taints []v1.Taint
+			for _, taint := range updated.Spec.Taints {
+				if taint.Key != nodeTaintPreempted {
+					taints = append(taints, taint)
+				}
+			}
+			updated.Spec.Taints = taints
+		}
+
+		_, err = pc.client.CoreV1().Nodes().Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func hasPreemptedTaint(node *v1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == nodeTaintPreempted {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,218 @@
+package yandex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	//nolint:staticcheck // Ignore SA1019. Need to keep deprecated package for compatibility.
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	ycsdkoperation "github.com/yandex-cloud/go-sdk/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/deckhouse/yandex-cloud-controller-manager/pkg/yapi"
+)
+
+// fakeTargetGroupService keeps target groups in memory, last is the target group changed by the last operation.
+type fakeTargetGroupService struct {
+	loadbalancer.TargetGroupServiceClient
+
+	targetGroups []*loadbalancer.TargetGroup
+	last         *loadbalancer.TargetGroup
+}
+
+func (f *fakeTargetGroupService) List(_ context.Context, in *loadbalancer.ListTargetGroupsRequest, _ ...grpc.CallOption) (*loadbalancer.ListTargetGroupsResponse, error) {
+	if in.Filter == "" {
+		return &loadbalancer.ListTargetGroupsResponse{TargetGroups: f.targetGroups}, nil
+	}
+
+	var ret []*loadbalancer.TargetGroup
+	for _, tg := range f.targetGroups {
+		if in.Filter == `name = "`+tg.Name+`"` {
+			ret = append(ret, tg)
+		}
+	}
+
+	return &loadbalancer.ListTargetGroupsResponse{TargetGroups: ret}, nil
+}
+
+func (f *fakeTargetGroupService) Get(_ context.Context, in *loadbalancer.GetTargetGroupRequest, _ ...grpc.CallOption) (*loadbalancer.TargetGroup, error) {
+	for _, tg := range f.targetGroups {
+		if tg.Id == in.TargetGroupId {
+			return tg, nil
+		}
+	}
+
+	return nil, status.Error(codes.NotFound, "target group not found")
+}
+
+func (f *fakeTargetGroupService) Create(_ context.Context, in *loadbalancer.CreateTargetGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	f.last = &loadbalancer.TargetGroup{Id: in.Name, Name: in.Name, Targets: in.Targets}
+	f.targetGroups = append(f.targetGroups, f.last)
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeTargetGroupService) AddTargets(_ context.Context, in *loadbalancer.AddTargetsRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, tg := range f.targetGroups {
+		if tg.Id == in.TargetGroupId {
+			tg.Targets = append(tg.Targets, in.Targets...)
+			f.last = tg
+		}
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeTargetGroupService) Delete(_ context.Context, in *loadbalancer.DeleteTargetGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	var tgs []*loadbalancer.TargetGroup
+	for _, tg := range f.targetGroups {
+		if tg.Id != in.TargetGroupId {
+			tgs = append(tgs, tg)
+		}
+	}
+	f.targetGroups = tgs
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func (f *fakeTargetGroupService) RemoveTargets(_ context.Context, in *loadbalancer.RemoveTargetsRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	for _, tg := range f.targetGroups {
+		if tg.Id != in.TargetGroupId {
+			continue
+		}
+
+		var targets []*loadbalancer.Target
+		for _, target := range tg.Targets {
+			var removed bool
+			for _, toRemove := range in.Targets {
+				removed = removed || target.Address == toRemove.Address
+			}
+			if !removed {
+				targets = append(targets, target)
+			}
+		}
+		tg.Targets = targets
+	}
+
+	return &operation.Operation{Done: true}, nil
+}
+
+func immediateOperationWaiter(_ context.Context, origFunc func() (*operation.Operation, error)) (proto.Message, *ycsdkoperation.Operation, error) {
+	_, err := origFunc()
+	return nil, nil, err
+}
+
+func TestPreemptionController(t *testing.T) {
+	instance := newTestInstance("id1", "vm1")
+	instance.Status = compute.Instance_STOPPING
+	instance.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+
+	tgSvc := &fakeTargetGroupService{targetGroups: []*loadbalancer.TargetGroup{{
+		Id:   "tg1",
+		Name: "clusternetwork",
+		Targets: []*loadbalancer.Target{
+			{SubnetId: "subnet", Address: "10.0.0.1"},
+			{SubnetId: "subnet", Address: "10.0.0.2"},
+		},
+	}}}
+
+	running := newTestInstance("id2", "vm2")
+	running.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: true}
+	instanceSvc := &fakeInstanceService{instances: []*compute.Instance{instance, running, newTestInstance("id3", "vm3")}}
+	yc := newTestCloud(instanceSvc)
+	yc.config.ClusterName = "cluster"
+	yc.yandexService.LbSvc = yapi.NewLoadBalancerService(nil, tgSvc, &yapi.CloudContext{FolderID: "folder", OperationWaiter: immediateOperationWaiter})
+	recorder := record.NewFakeRecorder(1)
+	yc.recorder = recorder
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "vm1", Labels: map[string]string{nodeLabelPreemptible: "true"}},
+		Spec:       v1.NodeSpec{ProviderID: "yandex://id1"},
+	}
+	otherNodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "vm2", Labels: map[string]string{nodeLabelPreemptible: "true"}},
+			Spec:       v1.NodeSpec{ProviderID: "yandex://id2"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gone", Labels: map[string]string{nodeLabelPreemptible: "true"}},
+			Spec:       v1.NodeSpec{ProviderID: "yandex://gone"},
+		},
+	}
+	client := fake.NewSimpleClientset(node, otherNodes[0], otherNodes[1])
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, n := range append(otherNodes, node) {
+		if err := indexer.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pc := &PreemptionController{
+		cloud:      yc,
+		client:     client,
+		nodeLister: listersv1.NewNodeLister(indexer),
+		source:     &computePreemptionSource{cloud: yc},
+	}
+	if err := pc.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// a single listing, and a single metadata lookup for the user stop marker of the stopping instance
+	if instanceSvc.calls != 2 {
+		t.Errorf("expected 2 API calls, got %d", instanceSvc.calls)
+	}
+
+	updated, err := client.CoreV1().Nodes().Get(context.Background(), "vm1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasPreemptedTaint(updated) {
+		t.Error("Node of the preempted instance should be tainted")
+	}
+	for _, otherNode := range otherNodes {
+		if n, _ := client.CoreV1().Nodes().Get(context.Background(), otherNode.Name, metav1.GetOptions{}); hasPreemptedTaint(n) {
+			t.Errorf("Node %q should not be tainted", otherNode.Name)
+		}
+	}
+
+	targets := tgSvc.targetGroups[0].Targets
+	if len(targets) != 1 || targets[0].Address != "10.0.0.2" {
+		t.Errorf("Node of the preempted instance should be removed from target groups, got %v", targets)
+	}
+
+	select {
+	case <-recorder.Events:
+	default:
+		t.Error("expected an InstancePreempted event")
+	}
+
+	// the taint is removed once the instance is running again
+	instance.Status = compute.Instance_RUNNING
+	if err := indexer.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	instanceSvc.calls = 0
+	if err := pc.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 1 {
+		t.Errorf("expected a single listing, got %d API calls", instanceSvc.calls)
+	}
+	updated, err = client.CoreV1().Nodes().Get(context.Background(), "vm1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasPreemptedTaint(updated) {
+		t.Error("taint should be removed from the Node of the restarted instance")
+	}
+}
@@ -2,6 +2,7 @@ package yandex
 
 import (
 	"context"
+	"net"
 	"sync"
 
 	"github.com/davecgh/go-spew/spew"
@@ -82,9 +83,15 @@ func (yc *Cloud) CreateRoute(ctx context.Context, _ string, _ string, route *clo
 		return err
 	}
 
+	// next hop must be of the same IP family as the destination
+	destinationIPFamily := v1.IPv4Protocol
+	if ip, _, err := net.ParseCIDR(route.DestinationCIDR); err == nil && ip.To4() == nil {
+		destinationIPFamily = v1.IPv6Protocol
+	}
+
 	var nextHop string
 	for _, address := range addresses {
-		if address.Type == v1.NodeInternalIP {
+		if address.Type == v1.NodeInternalIP && ipFamilyOf(address.Address) == destinationIPFamily {
 			nextHop = address.Address
 			break
 		}
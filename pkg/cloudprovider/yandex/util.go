@@ -25,6 +25,17 @@ func GetRegion(zoneName string) (string, error) {
 	return zoneName[:ix], nil
 }
 
+// providerIDFolder returns the folder of a deprecated "yandex://${folderID}/${zone}/${name}" provider ID,
+// or an empty string for other provider IDs.
+func providerIDFolder(providerID string) string {
+	deprecatedMatches := deprecatedRegExpProviderID.FindStringSubmatch(providerID)
+	if len(deprecatedMatches) == 4 {
+		return deprecatedMatches[1]
+	}
+
+	return ""
+}
+
 func MapNodeNameToInstanceName(nodeName types.NodeName) string {
 	return string(nodeName)
 }
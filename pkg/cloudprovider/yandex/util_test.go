@@ -19,6 +19,12 @@ func TestParseProviderID(t *testing.T) {
 	if len(name) == 0 {
 		t.Error("name field is empty")
 	}
+	if folder := providerIDFolder(deprecatedProviderID); folder != "folder" {
+		t.Errorf("unexpected folder %q of deprecatedProviderID", folder)
+	}
+	if folder := providerIDFolder(providerID); folder != "" {
+		t.Errorf("providerID should not have a folder, got %q", folder)
+	}
 
 	id, instanceNameIsId, err := ParseProviderID(providerID)
 	if err != nil {
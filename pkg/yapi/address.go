@@ -0,0 +1,113 @@
+package yapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// ReserveExternalAddress reserves an external IPv4 address under the given name.
+// The ephemeral currentAddress is made static if it is set, so that the load balancer using it keeps its IP,
+// otherwise a new address is allocated in the zone.
+func (ySvc *VPCService) ReserveExternalAddress(ctx context.Context, name string, labels map[string]string, zoneID, currentAddress string) (*vpc.Address, error) {
+	if len(currentAddress) > 0 {
+		address, err := ySvc.AddressSvc.GetByValue(ctx, &vpc.GetAddressByValueRequest{
+			Address: &vpc.GetAddressByValueRequest_ExternalIpv4Address{ExternalIpv4Address: currentAddress},
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return nil, err
+		}
+		if address != nil && !address.Reserved {
+			req := &vpc.UpdateAddressRequest{
+				AddressId:  address.Id,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "labels", "reserved"}},
+				Name:       name,
+				Labels:     labels,
+				Reserved:   true,
+			}
+			log.Printf("Reserving Address: %s", req.String())
+
+			result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+				return ySvc.AddressSvc.Update(ctx, req)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return result.(*vpc.Address), nil
+		}
+	}
+
+	req := &vpc.CreateAddressRequest{
+		FolderId: ySvc.cloudCtx.FolderID,
+		Name:     name,
+		Labels:   labels,
+		AddressSpec: &vpc.CreateAddressRequest_ExternalIpv4AddressSpec{
+			ExternalIpv4AddressSpec: &vpc.ExternalIpv4AddressSpec{ZoneId: zoneID},
+		},
+	}
+	log.Printf("Creating Address: %s", req.String())
+
+	result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.AddressSvc.Create(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*vpc.Address), nil
+}
+
+func (ySvc *VPCService) GetAddressByName(ctx context.Context, name string) (*vpc.Address, error) {
+	result, err := ySvc.AddressSvc.List(ctx, &vpc.ListAddressesRequest{
+		FolderId: ySvc.cloudCtx.FolderID,
+		PageSize: 2,
+		Filter:   fmt.Sprintf("name = \"%s\"", name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Addresses) > 1 {
+		return nil, fmt.Errorf("more than 1 Addresses found by the name %q", name)
+	}
+	if len(result.Addresses) == 0 {
+		return nil, nil
+	}
+
+	return result.Addresses[0], nil
+}
+
+// RemoveAddressByName releases the reserved address with the given name, if any.
+// Addresses missing any of the labels are not released.
+func (ySvc *VPCService) RemoveAddressByName(ctx context.Context, name string, labels map[string]string) error {
+	address, err := ySvc.GetAddressByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if address == nil {
+		return nil
+	}
+	for key, value := range labels {
+		if address.Labels[key] != value {
+			log.Printf("Address %q is not labeled with %s=%s, not deleting it", name, key, value)
+			return nil
+		}
+	}
+
+	log.Printf("Deleting Address by ID %q", address.Id)
+	_, _, err = ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.AddressSvc.Delete(ctx, &vpc.DeleteAddressRequest{AddressId: address.Id})
+	})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+
+	return nil
+}
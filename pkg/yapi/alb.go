@@ -0,0 +1,716 @@
+package yapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/apploadbalancer/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// albLabel is set on every resource of an ALB, the value is the name of the ALB.
+// The resources of removed listeners are found by it.
+const albLabel = "kubernetes-alb"
+
+// ApplicationLoadBalancerService manages Application Load Balancers along with the target group,
+// backend groups and HTTP routers each of them is built of. All the resources are named after the ALB.
+type ApplicationLoadBalancerService struct {
+	cloudCtx *CloudContext
+
+	AlbSvc          apploadbalancer.LoadBalancerServiceClient
+	BackendGroupSvc apploadbalancer.BackendGroupServiceClient
+	HttpRouterSvc   apploadbalancer.HttpRouterServiceClient
+	TgSvc           apploadbalancer.TargetGroupServiceClient
+}
+
+func NewApplicationLoadBalancerService(albSvc apploadbalancer.LoadBalancerServiceClient, bgSvc apploadbalancer.BackendGroupServiceClient,
+	routerSvc apploadbalancer.HttpRouterServiceClient, tgSvc apploadbalancer.TargetGroupServiceClient, cloudCtx *CloudContext) *ApplicationLoadBalancerService {
+
+	return &ApplicationLoadBalancerService{
+		cloudCtx: cloudCtx,
+
+		AlbSvc:          albSvc,
+		BackendGroupSvc: bgSvc,
+		HttpRouterSvc:   routerSvc,
+		TgSvc:           tgSvc,
+	}
+}
+
+// ALBSpec is the desired state of an Application Load Balancer.
+type ALBSpec struct {
+	Name string
+	// Labels are only updated if they are not nil
+	Labels map[string]string
+
+	NetworkID string
+	Locations []*apploadbalancer.Location
+
+	// Internal listeners get private addresses in SubnetID, Address pins the listener address
+	Internal bool
+	SubnetID string
+	Address  string
+
+	Targets     []*apploadbalancer.Target
+	HealthCheck *apploadbalancer.HealthCheck
+	Listeners   []ALBListener
+}
+
+// ALBListener routes all requests to a port of the targets. Listeners with certificates terminate TLS.
+type ALBListener struct {
+	Name           string
+	Port           int64
+	BackendPort    int64
+	CertificateIDs []string
+}
+
+// CreateOrUpdateALB makes the ALB and its resources match the spec.
+func (ySvc *ApplicationLoadBalancerService) CreateOrUpdateALB(ctx context.Context, spec *ALBSpec) (*apploadbalancer.LoadBalancer, error) {
+	tgID, err := ySvc.createOrUpdateTG(ctx, spec.Name, spec.Labels, spec.Targets)
+	if err != nil {
+		return nil, err
+	}
+
+	var listenerSpecs []*apploadbalancer.ListenerSpec
+	usedNames := make(map[string]struct{}, len(spec.Listeners))
+	for _, listener := range spec.Listeners {
+		name := albResourceName(spec.Name, listener.Name)
+		usedNames[name] = struct{}{}
+
+		bgID, err := ySvc.createOrUpdateBackendGroup(ctx, spec.Name, name, spec.Labels, &apploadbalancer.HttpBackendGroup{
+			Backends: []*apploadbalancer.HttpBackend{{
+				Name:          "nodes",
+				BackendWeight: wrapperspb.Int64(1),
+				Port:          listener.BackendPort,
+				BackendType: &apploadbalancer.HttpBackend_TargetGroups{
+					TargetGroups: &apploadbalancer.TargetGroupsBackend{TargetGroupIds: []string{tgID}},
+				},
+				Healthchecks: []*apploadbalancer.HealthCheck{spec.HealthCheck},
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		routerID, err := ySvc.createOrUpdateHttpRouter(ctx, spec.Name, name, spec.Labels, []*apploadbalancer.VirtualHost{{
+			Name: "default",
+			Routes: []*apploadbalancer.Route{{
+				Name: "default",
+				Route: &apploadbalancer.Route_Http{Http: &apploadbalancer.HttpRoute{
+					Match: &apploadbalancer.HttpRouteMatch{
+						Path: &apploadbalancer.StringMatch{Match: &apploadbalancer.StringMatch_PrefixMatch{PrefixMatch: "/"}},
+					},
+					Action: &apploadbalancer.HttpRoute_Route{Route: &apploadbalancer.HttpRouteAction{BackendGroupId: bgID}},
+				}},
+			}},
+		}})
+		if err != nil {
+			return nil, err
+		}
+
+		listenerSpecs = append(listenerSpecs, albListenerSpec(spec, listener, routerID))
+	}
+
+	alb, err := ySvc.GetALBByName(ctx, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	allocationPolicy := &apploadbalancer.AllocationPolicy{Locations: spec.Locations}
+
+	if alb == nil {
+		req := &apploadbalancer.CreateLoadBalancerRequest{
+			FolderId:         ySvc.cloudCtx.FolderID,
+			Name:             spec.Name,
+			Labels:           spec.Labels,
+			RegionId:         ySvc.cloudCtx.RegionID,
+			NetworkId:        spec.NetworkID,
+			ListenerSpecs:    listenerSpecs,
+			AllocationPolicy: allocationPolicy,
+		}
+		log.Printf("Creating ApplicationLoadBalancer: %s", req.String())
+
+		result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.AlbSvc.Create(ctx, req)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		alb = result.(*apploadbalancer.LoadBalancer)
+	} else {
+		req := &apploadbalancer.UpdateLoadBalancerRequest{
+			LoadBalancerId: alb.Id,
+			UpdateMask:     &fieldmaskpb.FieldMask{},
+		}
+		if !albListenersAreEqual(listenerSpecs, alb.Listeners) {
+			req.UpdateMask.Paths = append(req.UpdateMask.Paths, "listener_specs")
+			req.ListenerSpecs = listenerSpecs
+		}
+		if !albLocationsAreEqual(spec.Locations, alb.GetAllocationPolicy().GetLocations()) {
+			req.UpdateMask.Paths = append(req.UpdateMask.Paths, "allocation_policy")
+			req.AllocationPolicy = allocationPolicy
+		}
+		if spec.Labels != nil && !maps.Equal(spec.Labels, alb.Labels) {
+			req.UpdateMask.Paths = append(req.UpdateMask.Paths, "labels")
+			req.Labels = spec.Labels
+		}
+
+		if len(req.UpdateMask.Paths) > 0 {
+			log.Printf("Updating ApplicationLoadBalancer: %s", req.String())
+
+			result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+				return ySvc.AlbSvc.Update(ctx, req)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			alb = result.(*apploadbalancer.LoadBalancer)
+		}
+	}
+
+	// routers and backend groups of the removed listeners are no longer referenced by the ALB
+	if err := ySvc.removeListenerResources(ctx, spec.Name, usedNames); err != nil {
+		return nil, err
+	}
+
+	return alb, nil
+}
+
+// RemoveALBByName removes the ALB and all its resources.
+// The target group is removed last, so the resources are only looked for if the ALB or the target group exists.
+func (ySvc *ApplicationLoadBalancerService) RemoveALBByName(ctx context.Context, name string) error {
+	alb, err := ySvc.GetALBByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	tg, err := ySvc.getTGByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if alb == nil && tg == nil {
+		return nil
+	}
+
+	if alb != nil {
+		log.Printf("Deleting ApplicationLoadBalancer by ID %q", alb.Id)
+		_, _, err = ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.AlbSvc.Delete(ctx, &apploadbalancer.DeleteLoadBalancerRequest{LoadBalancerId: alb.Id})
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+
+	if err := ySvc.removeListenerResources(ctx, name, nil); err != nil {
+		return err
+	}
+
+	if tg != nil {
+		log.Printf("Deleting ApplicationLoadBalancer TargetGroup by ID %q", tg.Id)
+		_, _, err = ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.TgSvc.Delete(ctx, &apploadbalancer.DeleteTargetGroupRequest{TargetGroupId: tg.Id})
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ySvc *ApplicationLoadBalancerService) GetALBByName(ctx context.Context, name string) (*apploadbalancer.LoadBalancer, error) {
+	result, err := ySvc.AlbSvc.List(ctx, &apploadbalancer.ListLoadBalancersRequest{
+		FolderId: ySvc.cloudCtx.FolderID,
+		PageSize: 2,
+		Filter:   fmt.Sprintf("name = \"%s\"", name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.LoadBalancers) > 1 {
+		return nil, fmt.Errorf("more than 1 ApplicationLoadBalancers found by the name %q", name)
+	}
+	if len(result.LoadBalancers) == 0 {
+		return nil, nil
+	}
+
+	return result.LoadBalancers[0], nil
+}
+
+func (ySvc *ApplicationLoadBalancerService) getTGByName(ctx context.Context, name string) (*apploadbalancer.TargetGroup, error) {
+	result, err := ySvc.TgSvc.List(ctx, &apploadbalancer.ListTargetGroupsRequest{
+		FolderId: ySvc.cloudCtx.FolderID,
+		PageSize: 2,
+		Filter:   fmt.Sprintf("name = \"%s\"", name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.TargetGroups) > 1 {
+		return nil, fmt.Errorf("more than 1 ApplicationLoadBalancer TargetGroups found by the name %q", name)
+	}
+	if len(result.TargetGroups) == 0 {
+		return nil, nil
+	}
+
+	return result.TargetGroups[0], nil
+}
+
+func (ySvc *ApplicationLoadBalancerService) createOrUpdateTG(ctx context.Context, name string, labels map[string]string, targets []*apploadbalancer.Target) (string, error) {
+	tg, err := ySvc.getTGByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if tg == nil {
+		req := &apploadbalancer.CreateTargetGroupRequest{
+			FolderId: ySvc.cloudCtx.FolderID,
+			Name:     name,
+			Labels:   albResourceLabels(name, labels, nil),
+			Targets:  targets,
+		}
+		log.Printf("Creating ApplicationLoadBalancer TargetGroup: %s", req.String())
+
+		result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.TgSvc.Create(ctx, req)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return result.(*apploadbalancer.TargetGroup).Id, nil
+	}
+
+	if err := ySvc.updateTG(ctx, tg, albResourceLabels(tg.Name, labels, tg.Labels), targets); err != nil {
+		return "", err
+	}
+
+	return tg.Id, nil
+}
+
+// UpdateTGTargets sets the targets of the target group of an existing ALB, nothing is done if there is no such ALB.
+func (ySvc *ApplicationLoadBalancerService) UpdateTGTargets(ctx context.Context, albName string, targets []*apploadbalancer.Target) error {
+	tg, err := ySvc.getTGByName(ctx, albName)
+	if err != nil || tg == nil {
+		return err
+	}
+
+	return ySvc.updateTG(ctx, tg, tg.Labels, targets)
+}
+
+func (ySvc *ApplicationLoadBalancerService) updateTG(ctx context.Context, tg *apploadbalancer.TargetGroup, labels map[string]string, targets []*apploadbalancer.Target) error {
+	req := &apploadbalancer.UpdateTargetGroupRequest{
+		TargetGroupId: tg.Id,
+		UpdateMask:    &fieldmaskpb.FieldMask{},
+	}
+	if !albTargetsAreEqual(targets, tg.Targets) {
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "targets")
+		req.Targets = targets
+	}
+	if !maps.Equal(labels, tg.Labels) {
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "labels")
+		req.Labels = labels
+	}
+	if len(req.UpdateMask.Paths) == 0 {
+		return nil
+	}
+
+	log.Printf("Updating ApplicationLoadBalancer TargetGroup: %s", req.String())
+
+	_, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.TgSvc.Update(ctx, req)
+	})
+
+	return err
+}
+
+func (ySvc *ApplicationLoadBalancerService) createOrUpdateBackendGroup(ctx context.Context, albName, name string, labels map[string]string, backendGroup *apploadbalancer.HttpBackendGroup) (string, error) {
+	result, err := ySvc.BackendGroupSvc.List(ctx, &apploadbalancer.ListBackendGroupsRequest{
+		FolderId: ySvc.cloudCtx.FolderID,
+		PageSize: 2,
+		Filter:   fmt.Sprintf("name = \"%s\"", name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.BackendGroups) == 0 {
+		req := &apploadbalancer.CreateBackendGroupRequest{
+			FolderId: ySvc.cloudCtx.FolderID,
+			Name:     name,
+			Labels:   albResourceLabels(albName, labels, nil),
+			Backend:  &apploadbalancer.CreateBackendGroupRequest_Http{Http: backendGroup},
+		}
+		log.Printf("Creating BackendGroup: %s", req.String())
+
+		result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.BackendGroupSvc.Create(ctx, req)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return result.(*apploadbalancer.BackendGroup).Id, nil
+	}
+
+	existing := result.BackendGroups[0]
+	req := &apploadbalancer.UpdateBackendGroupRequest{
+		BackendGroupId: existing.Id,
+		UpdateMask:     &fieldmaskpb.FieldMask{},
+	}
+	if !albBackendGroupsAreEqual(backendGroup, existing.GetHttp()) {
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "http")
+		req.Backend = &apploadbalancer.UpdateBackendGroupRequest_Http{Http: backendGroup}
+	}
+	if labels := albResourceLabels(albName, labels, existing.Labels); !maps.Equal(labels, existing.Labels) {
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "labels")
+		req.Labels = labels
+	}
+	if len(req.UpdateMask.Paths) == 0 {
+		return existing.Id, nil
+	}
+
+	log.Printf("Updating BackendGroup: %s", req.String())
+
+	_, _, err = ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.BackendGroupSvc.Update(ctx, req)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return existing.Id, nil
+}
+
+func (ySvc *ApplicationLoadBalancerService) createOrUpdateHttpRouter(ctx context.Context, albName, name string, labels map[string]string, virtualHosts []*apploadbalancer.VirtualHost) (string, error) {
+	result, err := ySvc.HttpRouterSvc.List(ctx, &apploadbalancer.ListHttpRoutersRequest{
+		FolderId: ySvc.cloudCtx.FolderID,
+		PageSize: 2,
+		Filter:   fmt.Sprintf("name = \"%s\"", name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.HttpRouters) == 0 {
+		req := &apploadbalancer.CreateHttpRouterRequest{
+			FolderId:     ySvc.cloudCtx.FolderID,
+			Name:         name,
+			Labels:       albResourceLabels(albName, labels, nil),
+			VirtualHosts: virtualHosts,
+		}
+		log.Printf("Creating HttpRouter: %s", req.String())
+
+		result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.HttpRouterSvc.Create(ctx, req)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return result.(*apploadbalancer.HttpRouter).Id, nil
+	}
+
+	existing := result.HttpRouters[0]
+	req := &apploadbalancer.UpdateHttpRouterRequest{
+		HttpRouterId: existing.Id,
+		UpdateMask:   &fieldmaskpb.FieldMask{},
+	}
+	if !albVirtualHostsAreEqual(virtualHosts, existing.VirtualHosts) {
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "virtual_hosts")
+		req.VirtualHosts = virtualHosts
+	}
+	if labels := albResourceLabels(albName, labels, existing.Labels); !maps.Equal(labels, existing.Labels) {
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "labels")
+		req.Labels = labels
+	}
+	if len(req.UpdateMask.Paths) == 0 {
+		return existing.Id, nil
+	}
+
+	log.Printf("Updating HttpRouter: %s", req.String())
+
+	_, _, err = ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.HttpRouterSvc.Update(ctx, req)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return existing.Id, nil
+}
+
+// removeListenerResources removes the HTTP routers and backend groups labeled with the ALB name except for the ones named in keep.
+func (ySvc *ApplicationLoadBalancerService) removeListenerResources(ctx context.Context, albName string, keep map[string]struct{}) error {
+	var routers []*apploadbalancer.HttpRouter
+	pageToken := ""
+	for {
+		result, err := ySvc.HttpRouterSvc.List(ctx, &apploadbalancer.ListHttpRoutersRequest{
+			FolderId:  ySvc.cloudCtx.FolderID,
+			PageSize:  1000,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return err
+		}
+		routers = append(routers, result.HttpRouters...)
+
+		pageToken = result.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	for _, router := range routers {
+		if _, ok := keep[router.Name]; ok || router.Labels[albLabel] != albName {
+			continue
+		}
+
+		log.Printf("Deleting HttpRouter %q", router.Name)
+		_, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.HttpRouterSvc.Delete(ctx, &apploadbalancer.DeleteHttpRouterRequest{HttpRouterId: router.Id})
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+
+	var backendGroups []*apploadbalancer.BackendGroup
+	pageToken = ""
+	for {
+		result, err := ySvc.BackendGroupSvc.List(ctx, &apploadbalancer.ListBackendGroupsRequest{
+			FolderId:  ySvc.cloudCtx.FolderID,
+			PageSize:  1000,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return err
+		}
+		backendGroups = append(backendGroups, result.BackendGroups...)
+
+		pageToken = result.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	for _, backendGroup := range backendGroups {
+		if _, ok := keep[backendGroup.Name]; ok || backendGroup.Labels[albLabel] != albName {
+			continue
+		}
+
+		log.Printf("Deleting BackendGroup %q", backendGroup.Name)
+		_, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.BackendGroupSvc.Delete(ctx, &apploadbalancer.DeleteBackendGroupRequest{BackendGroupId: backendGroup.Id})
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func albResourceName(albName, listenerName string) string {
+	return albName + "-" + listenerName
+}
+
+// albResourceLabels returns the spec labels, or the current ones if the spec doesn't set them, along with the ALB label.
+func albResourceLabels(albName string, labels, current map[string]string) map[string]string {
+	if labels == nil {
+		labels = current
+	}
+
+	ret := maps.Clone(labels)
+	if ret == nil {
+		ret = make(map[string]string, 1)
+	}
+	ret[albLabel] = albName
+
+	return ret
+}
+
+func albListenerSpec(spec *ALBSpec, listener ALBListener, routerID string) *apploadbalancer.ListenerSpec {
+	addressSpec := &apploadbalancer.AddressSpec{}
+	if spec.Internal {
+		addressSpec.AddressSpec = &apploadbalancer.AddressSpec_InternalIpv4AddressSpec{
+			InternalIpv4AddressSpec: &apploadbalancer.InternalIpv4AddressSpec{Address: spec.Address, SubnetId: spec.SubnetID},
+		}
+	} else {
+		addressSpec.AddressSpec = &apploadbalancer.AddressSpec_ExternalIpv4AddressSpec{
+			ExternalIpv4AddressSpec: &apploadbalancer.ExternalIpv4AddressSpec{Address: spec.Address},
+		}
+	}
+
+	listenerSpec := &apploadbalancer.ListenerSpec{
+		Name: listener.Name,
+		EndpointSpecs: []*apploadbalancer.EndpointSpec{{
+			AddressSpecs: []*apploadbalancer.AddressSpec{addressSpec},
+			Ports:        []int64{listener.Port},
+		}},
+	}
+
+	handler := &apploadbalancer.HttpHandler{HttpRouterId: routerID}
+	if len(listener.CertificateIDs) > 0 {
+		listenerSpec.Listener = &apploadbalancer.ListenerSpec_Tls{Tls: &apploadbalancer.TlsListener{
+			DefaultHandler: &apploadbalancer.TlsHandler{
+				Handler:        &apploadbalancer.TlsHandler_HttpHandler{HttpHandler: handler},
+				CertificateIds: listener.CertificateIDs,
+			},
+		}}
+	} else {
+		listenerSpec.Listener = &apploadbalancer.ListenerSpec_Http{Http: &apploadbalancer.HttpListener{Handler: handler}}
+	}
+
+	return listenerSpec
+}
+
+// albListenersAreEqual compares the listener specs with the listeners of an existing ALB.
+// Addresses assigned by the cloud match the specs without a pinned address.
+func albListenersAreEqual(specs []*apploadbalancer.ListenerSpec, listeners []*apploadbalancer.Listener) bool {
+	if len(specs) != len(listeners) {
+		return false
+	}
+
+	listenersByName := make(map[string]*apploadbalancer.Listener, len(listeners))
+	for _, listener := range listeners {
+		listenersByName[listener.Name] = listener
+	}
+
+	for _, spec := range specs {
+		listener, ok := listenersByName[spec.Name]
+		if !ok || len(spec.EndpointSpecs) != len(listener.Endpoints) {
+			return false
+		}
+
+		for i, endpointSpec := range spec.EndpointSpecs {
+			endpoint := listener.Endpoints[i]
+			if !slices.Equal(endpointSpec.Ports, endpoint.Ports) || len(endpointSpec.AddressSpecs) != len(endpoint.Addresses) {
+				return false
+			}
+			for j, addressSpec := range endpointSpec.AddressSpecs {
+				if !albAddressMatches(addressSpec, endpoint.Addresses[j]) {
+					return false
+				}
+			}
+		}
+
+		if spec.GetHttp() != nil {
+			if listener.GetHttp().GetHandler().GetHttpRouterId() != spec.GetHttp().GetHandler().GetHttpRouterId() {
+				return false
+			}
+		} else {
+			specHandler, handler := spec.GetTls().GetDefaultHandler(), listener.GetTls().GetDefaultHandler()
+			if handler.GetHttpHandler().GetHttpRouterId() != specHandler.GetHttpHandler().GetHttpRouterId() ||
+				!slices.Equal(handler.GetCertificateIds(), specHandler.GetCertificateIds()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func albAddressMatches(spec *apploadbalancer.AddressSpec, address *apploadbalancer.Address) bool {
+	switch {
+	case spec.GetExternalIpv4AddressSpec() != nil:
+		external := address.GetExternalIpv4Address()
+		return external != nil && (spec.GetExternalIpv4AddressSpec().Address == "" || spec.GetExternalIpv4AddressSpec().Address == external.Address)
+	case spec.GetInternalIpv4AddressSpec() != nil:
+		internal := address.GetInternalIpv4Address()
+		internalSpec := spec.GetInternalIpv4AddressSpec()
+		return internal != nil && internalSpec.SubnetId == internal.SubnetId && (internalSpec.Address == "" || internalSpec.Address == internal.Address)
+	case spec.GetExternalIpv6AddressSpec() != nil:
+		external := address.GetExternalIpv6Address()
+		return external != nil && (spec.GetExternalIpv6AddressSpec().Address == "" || spec.GetExternalIpv6AddressSpec().Address == external.Address)
+	}
+
+	return false
+}
+
+func albLocationsAreEqual(expected, actual []*apploadbalancer.Location) bool {
+	return albLocationSet(expected).Equal(albLocationSet(actual))
+}
+
+func albTargetsAreEqual(expected, actual []*apploadbalancer.Target) bool {
+	return albTargetSet(expected).Equal(albTargetSet(actual))
+}
+
+// albVirtualHostsAreEqual compares the parts of the virtual hosts set by the CCM,
+// the cloud fills in defaults for the rest of the fields.
+func albVirtualHostsAreEqual(expected, actual []*apploadbalancer.VirtualHost) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i].Name != actual[i].Name || len(expected[i].Routes) != len(actual[i].Routes) {
+			return false
+		}
+		for j, route := range expected[i].Routes {
+			actualRoute := actual[i].Routes[j]
+			if route.Name != actualRoute.Name ||
+				route.GetHttp().GetMatch().GetPath().GetPrefixMatch() != actualRoute.GetHttp().GetMatch().GetPath().GetPrefixMatch() ||
+				route.GetHttp().GetRoute().GetBackendGroupId() != actualRoute.GetHttp().GetRoute().GetBackendGroupId() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// albBackendGroupsAreEqual compares the parts of the backend groups set by the CCM.
+func albBackendGroupsAreEqual(expected, actual *apploadbalancer.HttpBackendGroup) bool {
+	if len(expected.GetBackends()) != len(actual.GetBackends()) {
+		return false
+	}
+	for i, backend := range expected.GetBackends() {
+		actualBackend := actual.Backends[i]
+		if backend.Name != actualBackend.Name || backend.Port != actualBackend.Port ||
+			!slices.Equal(backend.GetTargetGroups().GetTargetGroupIds(), actualBackend.GetTargetGroups().GetTargetGroupIds()) ||
+			len(backend.Healthchecks) != len(actualBackend.Healthchecks) {
+			return false
+		}
+		for j, hc := range backend.Healthchecks {
+			actualHC := actualBackend.Healthchecks[j]
+			if !proto.Equal(hc.Timeout, actualHC.Timeout) || !proto.Equal(hc.Interval, actualHC.Interval) ||
+				hc.HealthyThreshold != actualHC.HealthyThreshold || hc.UnhealthyThreshold != actualHC.UnhealthyThreshold ||
+				hc.HealthcheckPort != actualHC.HealthcheckPort ||
+				!proto.Equal(hc.GetHttp(), actualHC.GetHttp()) || !proto.Equal(hc.GetStream(), actualHC.GetStream()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func albLocationSet(locations []*apploadbalancer.Location) sets.Set[string] {
+	ret := sets.New[string]()
+	for _, location := range locations {
+		ret.Insert(fmt.Sprintf("%s:%s:%t", location.ZoneId, location.SubnetId, location.DisableTraffic))
+	}
+
+	return ret
+}
+
+func albTargetSet(targets []*apploadbalancer.Target) sets.Set[string] {
+	ret := sets.New[string]()
+	for _, target := range targets {
+		ret.Insert(fmt.Sprintf("%s:%s", target.SubnetId, target.GetIpAddress()))
+	}
+
+	return ret
+}
@@ -0,0 +1,97 @@
+package yapi
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/apploadbalancer/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	ycsdkoperation "github.com/yandex-cloud/go-sdk/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeHttpRouterService serves routers from memory one per page and records deletions.
+type fakeHttpRouterService struct {
+	apploadbalancer.HttpRouterServiceClient
+
+	routers []*apploadbalancer.HttpRouter
+	deleted []string
+}
+
+func (f *fakeHttpRouterService) List(_ context.Context, in *apploadbalancer.ListHttpRoutersRequest, _ ...grpc.CallOption) (*apploadbalancer.ListHttpRoutersResponse, error) {
+	start, _ := strconv.Atoi(in.PageToken)
+	resp := &apploadbalancer.ListHttpRoutersResponse{HttpRouters: f.routers[start : start+1]}
+	if start+1 < len(f.routers) {
+		resp.NextPageToken = strconv.Itoa(start + 1)
+	}
+
+	return resp, nil
+}
+
+func (f *fakeHttpRouterService) Delete(_ context.Context, in *apploadbalancer.DeleteHttpRouterRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	f.deleted = append(f.deleted, in.HttpRouterId)
+	return &operation.Operation{}, nil
+}
+
+// fakeBackendGroupService serves backend groups from memory one per page and records deletions.
+type fakeBackendGroupService struct {
+	apploadbalancer.BackendGroupServiceClient
+
+	backendGroups []*apploadbalancer.BackendGroup
+	deleted       []string
+}
+
+func (f *fakeBackendGroupService) List(_ context.Context, in *apploadbalancer.ListBackendGroupsRequest, _ ...grpc.CallOption) (*apploadbalancer.ListBackendGroupsResponse, error) {
+	start, _ := strconv.Atoi(in.PageToken)
+	resp := &apploadbalancer.ListBackendGroupsResponse{BackendGroups: f.backendGroups[start : start+1]}
+	if start+1 < len(f.backendGroups) {
+		resp.NextPageToken = strconv.Itoa(start + 1)
+	}
+
+	return resp, nil
+}
+
+func (f *fakeBackendGroupService) Delete(_ context.Context, in *apploadbalancer.DeleteBackendGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	f.deleted = append(f.deleted, in.BackendGroupId)
+	return &operation.Operation{}, nil
+}
+
+func TestRemoveListenerResources(t *testing.T) {
+	labels := func(albName string) map[string]string {
+		return map[string]string{albLabel: albName}
+	}
+
+	routerSvc := &fakeHttpRouterService{routers: []*apploadbalancer.HttpRouter{
+		{Id: "kept", Name: "alb-http", Labels: labels("alb")},
+		{Id: "removed", Name: "alb-https", Labels: labels("alb")},
+		{Id: "same-prefix", Name: "alb-other", Labels: labels("alb-other")},
+		{Id: "unlabeled", Name: "alb-manual"},
+	}}
+	bgSvc := &fakeBackendGroupService{backendGroups: []*apploadbalancer.BackendGroup{
+		{Id: "unlabeled", Name: "alb-manual"},
+		{Id: "removed", Name: "alb-https", Labels: labels("alb")},
+		{Id: "kept", Name: "alb-http", Labels: labels("alb")},
+	}}
+
+	svc := NewApplicationLoadBalancerService(nil, bgSvc, routerSvc, nil, &CloudContext{
+		FolderID: "folder",
+		OperationWaiter: func(_ context.Context, origFunc func() (*operation.Operation, error)) (proto.Message, *ycsdkoperation.Operation, error) {
+			_, err := origFunc()
+			return nil, nil, err
+		},
+	})
+
+	if err := svc.removeListenerResources(context.Background(), "alb", map[string]struct{}{"alb-http": {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(routerSvc.deleted, []string{"removed"}) {
+		t.Errorf("expected only the labeled router of the removed listener to be deleted, deleted %v", routerSvc.deleted)
+	}
+	if !slices.Equal(bgSvc.deleted, []string{"removed"}) {
+		t.Errorf("expected only the labeled backend group of the removed listener to be deleted, deleted %v", bgSvc.deleted)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
 	//nolint:staticcheck // Ignore SA1019. Need to keep deprecated package for compatibility.
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
 	ycsdk "github.com/yandex-cloud/go-sdk"
@@ -26,6 +27,12 @@ type YandexCloudAPI struct {
 	VPCSvc     *VPCService
 	ComputeSvc *ComputeService
 	LbSvc      *LoadBalancerService
+	AlbSvc     *ApplicationLoadBalancerService
+
+	InstanceGroupSvc *InstanceGroupService
+
+	// IAMTokenSvc is used to exchange credentials for IAM tokens, it does not require authentication
+	IAMTokenSvc iam.IamTokenServiceClient
 
 	OperationWaiter OperationWaiter
 }
@@ -62,12 +69,26 @@ func NewYandexCloudAPI(creds ycsdk.Credentials, regionID, folderID string) (*Yan
 		OperationWaiter: opWaiter,
 	}
 
+	computeSvc := NewComputeService(sdk.Compute().Instance(), sdk.Compute().Zone(), cloudCtx)
+	computeSvc.instanceFolders.folderSvc = sdk.ResourceManager().Folder()
+
+	instanceGroupSvc := NewInstanceGroupService(sdk.InstanceGroup().InstanceGroup(), cloudCtx)
+	instanceGroupSvc.folderIDs = computeSvc.InstanceFolderIDs
+
+	alb := sdk.ApplicationLoadBalancer()
+	albSvc := NewApplicationLoadBalancerService(alb.LoadBalancer(), alb.BackendGroup(), alb.HttpRouter(), alb.TargetGroup(), cloudCtx)
+
 	return &YandexCloudAPI{
 		LbSvc:      NewLoadBalancerService(sdk.LoadBalancer().NetworkLoadBalancer(), sdk.LoadBalancer().TargetGroup(), cloudCtx),
-		ComputeSvc: NewComputeService(sdk.Compute().Instance(), sdk.Compute().Zone(), cloudCtx),
-		VPCSvc:     NewVPCService(sdk.VPC().Network(), sdk.VPC().Subnet(), sdk.VPC().RouteTable(), cloudCtx),
+		AlbSvc:     albSvc,
+		ComputeSvc: computeSvc,
+		VPCSvc:     NewVPCService(sdk.VPC().Network(), sdk.VPC().Subnet(), sdk.VPC().RouteTable(), sdk.VPC().Address(), cloudCtx),
 		cloudCtx:   cloudCtx,
 
+		InstanceGroupSvc: instanceGroupSvc,
+
+		IAMTokenSvc: sdk.IAM().IamToken(),
+
 		OperationWaiter: opWaiter,
 	}, nil
 }
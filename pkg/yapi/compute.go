@@ -12,6 +12,9 @@ type ComputeService struct {
 
 	InstanceSvc compute.InstanceServiceClient
 	ZoneSvc     compute.ZoneServiceClient
+
+	instanceCache   *instanceCache
+	instanceFolders instanceFolders
 }
 
 func NewComputeService(iSvc compute.InstanceServiceClient, zSvc compute.ZoneServiceClient,
@@ -21,12 +24,64 @@ func NewComputeService(iSvc compute.InstanceServiceClient, zSvc compute.ZoneServ
 		cloudCtx:    cloudCtx,
 		InstanceSvc: iSvc,
 		ZoneSvc:     zSvc,
+
+		instanceCache: newInstanceCache(),
 	}
 }
 
+// FindInstanceByName searches all the instance folders for the instance, returns nil if there is none.
+// Instance names are unique within a folder only, so an error is returned if several folders have one.
 func (cs *ComputeService) FindInstanceByName(ctx context.Context, instanceName string) (*compute.Instance, error) {
+	if instance := cs.cachedInstanceLookup(ctx, func(c *instanceCache) (*compute.Instance, bool) { return c.getByName("", instanceName) }); instance != nil {
+		return instance, nil
+	}
+
+	folderIDs, err := cs.InstanceFolderIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *compute.Instance
+	for _, folderID := range folderIDs {
+		instance, err := cs.findInstanceInFolder(ctx, folderID, instanceName)
+		if err != nil {
+			return nil, err
+		}
+		if instance == nil {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("more than 1 Instances found by the name %q in folders %q and %q", instanceName, found.FolderId, instance.FolderId)
+		}
+		found = instance
+	}
+
+	if found != nil {
+		cs.instanceCache.store(found)
+	}
+
+	return found, nil
+}
+
+// FindInstanceByFolderAndName returns the instance with the name from the folder, or nil if there is none.
+func (cs *ComputeService) FindInstanceByFolderAndName(ctx context.Context, folderID, instanceName string) (*compute.Instance, error) {
+	if instance := cs.cachedInstanceLookup(ctx, func(c *instanceCache) (*compute.Instance, bool) { return c.getByName(folderID, instanceName) }); instance != nil {
+		return instance, nil
+	}
+
+	instance, err := cs.findInstanceInFolder(ctx, folderID, instanceName)
+	if err != nil || instance == nil {
+		return nil, err
+	}
+
+	cs.instanceCache.store(instance)
+
+	return instance, nil
+}
+
+func (cs *ComputeService) findInstanceInFolder(ctx context.Context, folderID, instanceName string) (*compute.Instance, error) {
 	result, err := cs.InstanceSvc.List(ctx, &compute.ListInstancesRequest{
-		FolderId: cs.cloudCtx.FolderID,
+		FolderId: folderID,
 		PageSize: 2,
 		Filter:   fmt.Sprintf("name = \"%s\"", instanceName),
 	})
@@ -39,8 +94,133 @@ func (cs *ComputeService) FindInstanceByName(ctx context.Context, instanceName s
 		return nil, fmt.Errorf("more than 1 Instances found by the name %q", instanceName)
 	}
 	if len(result.Instances) == 0 {
-		return nil, fmt.Errorf("no than 1 Instances found by the name %q", instanceName)
+		return nil, nil
 	}
 
 	return result.Instances[0], nil
 }
+
+// GetInstanceByID returns the instance by its ID. API errors, including NotFound, are returned as is.
+func (cs *ComputeService) GetInstanceByID(ctx context.Context, instanceID string) (*compute.Instance, error) {
+	if instance := cs.cachedInstanceLookup(ctx, func(c *instanceCache) (*compute.Instance, bool) { return c.getByID(instanceID) }); instance != nil {
+		return instance, nil
+	}
+
+	instance, err := cs.InstanceSvc.Get(ctx, &compute.GetInstanceRequest{InstanceId: instanceID})
+	if err != nil {
+		return nil, err
+	}
+
+	cs.instanceCache.store(instance)
+
+	return instance, nil
+}
+
+// GetInstanceMetadata returns the metadata of an instance, which is only returned by the API in the full view.
+// It is never cached.
+func (cs *ComputeService) GetInstanceMetadata(ctx context.Context, instanceID string) (map[string]string, error) {
+	instance, err := cs.InstanceSvc.Get(ctx, &compute.GetInstanceRequest{InstanceId: instanceID, View: compute.InstanceView_FULL})
+	if err != nil {
+		return nil, err
+	}
+
+	return instance.Metadata, nil
+}
+
+// ListInstances returns all instances of the instance folders, from the cache if it is synced.
+func (cs *ComputeService) ListInstances(ctx context.Context) ([]*compute.Instance, error) {
+	instances, _, err := cs.listInstances(ctx)
+	return instances, err
+}
+
+// listInstances also tells whether the instances were served from the cache.
+func (cs *ComputeService) listInstances(ctx context.Context) (instances []*compute.Instance, cached bool, err error) {
+	if !cacheBypassed(ctx) {
+		if instances, ok := cs.instanceCache.list(); ok {
+			instanceCacheRequests.WithLabelValues(cacheResultHit).Inc()
+			return instances, true, nil
+		}
+	}
+
+	instanceCacheRequests.WithLabelValues(cacheResultBypass).Inc()
+	instances, err = cs.listAllInstances(ctx)
+	return instances, false, err
+}
+
+func (cs *ComputeService) listAllInstances(ctx context.Context) ([]*compute.Instance, error) {
+	folderIDs, err := cs.InstanceFolderIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []*compute.Instance
+	for _, folderID := range folderIDs {
+		var pageToken string
+		for {
+			result, err := cs.InstanceSvc.List(ctx, &compute.ListInstancesRequest{
+				FolderId:  folderID,
+				PageSize:  1000,
+				PageToken: pageToken,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			instances = append(instances, result.Instances...)
+
+			pageToken = result.NextPageToken
+			if pageToken == "" {
+				break
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// GetZoneRegion returns the ID of the region the zone belongs to.
+func (cs *ComputeService) GetZoneRegion(ctx context.Context, zoneID string) (string, error) {
+	zone, err := cs.ZoneSvc.Get(ctx, &compute.GetZoneRequest{ZoneId: zoneID})
+	if err != nil {
+		return "", err
+	}
+
+	return zone.RegionId, nil
+}
+
+// FindInstance returns the only instance of the instance folders matching the predicate, or nil if there are none.
+// Cached instances are searched first, and the API is consulted if none of them match.
+func (cs *ComputeService) FindInstance(ctx context.Context, description string, match func(*compute.Instance) bool) (*compute.Instance, error) {
+	instances, cached, err := cs.listInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := findSingleInstance(instances, description, match)
+	if err != nil || instance != nil || !cached {
+		return instance, err
+	}
+
+	// the instance may have been created after the last cache refresh
+	instances, err = cs.ListInstances(WithCacheBypass(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return findSingleInstance(instances, description, match)
+}
+
+func findSingleInstance(instances []*compute.Instance, description string, match func(*compute.Instance) bool) (*compute.Instance, error) {
+	var found *compute.Instance
+	for _, instance := range instances {
+		if !match(instance) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("more than 1 Instances found by %s", description)
+		}
+		found = instance
+	}
+
+	return found, nil
+}
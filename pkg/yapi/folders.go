@@ -0,0 +1,102 @@
+package yapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/resourcemanager/v1"
+)
+
+// folders of a cloud are created rarely, so they are listed only every few minutes
+const defaultCloudFoldersTTL = 10 * time.Minute
+
+// instanceFolders are the folders searched for instances: the cluster's folder,
+// a static list of additional folders and, optionally, all folders of a cloud.
+type instanceFolders struct {
+	folderSvc resourcemanager.FolderServiceClient
+
+	folderIDs []string
+	cloudID   string
+
+	mu           sync.Mutex
+	cloudFolders []string
+	expiresAt    time.Time
+}
+
+// SetInstanceFolders makes instances be searched in the additional folders and in all folders of the cloud, if set.
+func (cs *ComputeService) SetInstanceFolders(folderIDs []string, cloudID string) {
+	cs.instanceFolders.folderIDs = folderIDs
+	cs.instanceFolders.cloudID = cloudID
+}
+
+// InstanceFolderIDs returns the IDs of all folders searched for instances, the cluster's folder goes first.
+func (cs *ComputeService) InstanceFolderIDs(ctx context.Context) ([]string, error) {
+	folderIDs := []string{cs.cloudCtx.FolderID}
+	folderIDs = appendUnique(folderIDs, cs.instanceFolders.folderIDs...)
+
+	if len(cs.instanceFolders.cloudID) > 0 {
+		cloudFolders, err := cs.instanceFolders.listCloudFolders(ctx)
+		if err != nil {
+			return nil, err
+		}
+		folderIDs = appendUnique(folderIDs, cloudFolders...)
+	}
+
+	return folderIDs, nil
+}
+
+func (f *instanceFolders) listCloudFolders(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cloudFolders != nil && time.Now().Before(f.expiresAt) {
+		return f.cloudFolders, nil
+	}
+
+	var (
+		folderIDs []string
+		pageToken string
+	)
+	for {
+		result, err := f.folderSvc.List(ctx, &resourcemanager.ListFoldersRequest{
+			CloudId:   f.cloudID,
+			PageSize:  1000,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, folder := range result.Folders {
+			folderIDs = append(folderIDs, folder.Id)
+		}
+
+		pageToken = result.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	f.cloudFolders = folderIDs
+	f.expiresAt = time.Now().Add(defaultCloudFoldersTTL)
+
+	return folderIDs, nil
+}
+
+func appendUnique(values []string, newValues ...string) []string {
+	for _, newValue := range newValues {
+		var found bool
+		for _, value := range values {
+			if value == newValue {
+				found = true
+				break
+			}
+		}
+		if !found && len(newValue) > 0 {
+			values = append(values, newValue)
+		}
+	}
+
+	return values
+}
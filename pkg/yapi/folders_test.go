@@ -0,0 +1,75 @@
+package yapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/resourcemanager/v1"
+	"google.golang.org/grpc"
+)
+
+type fakeFolderService struct {
+	resourcemanager.FolderServiceClient
+
+	folders []string
+}
+
+func (f *fakeFolderService) List(_ context.Context, _ *resourcemanager.ListFoldersRequest, _ ...grpc.CallOption) (*resourcemanager.ListFoldersResponse, error) {
+	resp := &resourcemanager.ListFoldersResponse{}
+	for _, folderID := range f.folders {
+		resp.Folders = append(resp.Folders, &resourcemanager.Folder{Id: folderID})
+	}
+
+	return resp, nil
+}
+
+func TestMultiFolderInstanceLookup(t *testing.T) {
+	instanceSvc := &fakeInstanceService{instances: []*compute.Instance{
+		{Id: "id1", Name: "node1", FolderId: "folder"},
+		{Id: "id2", Name: "gpu1", FolderId: "gpu-folder"},
+		{Id: "id3", Name: "dup", FolderId: "folder"},
+		{Id: "id4", Name: "dup", FolderId: "gpu-folder"},
+	}}
+	cs := NewComputeService(instanceSvc, nil, &CloudContext{FolderID: "folder"})
+	ctx := context.Background()
+
+	if instance, err := cs.FindInstanceByName(ctx, "gpu1"); instance != nil || err != nil {
+		t.Errorf("instances of other folders should not be found by default, got %v, %v", instance, err)
+	}
+
+	cs.SetInstanceFolders(nil, "cloud")
+	cs.instanceFolders.folderSvc = &fakeFolderService{folders: []string{"folder", "gpu-folder"}}
+
+	folderIDs, err := cs.InstanceFolderIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folderIDs) != 2 || folderIDs[0] != "folder" {
+		t.Errorf("unexpected folders %v", folderIDs)
+	}
+
+	instance, err := cs.FindInstanceByName(ctx, "gpu1")
+	if err != nil || instance.Id != "id2" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+	if _, err := cs.FindInstanceByName(ctx, "dup"); err == nil {
+		t.Error("should return non-nil err for names found in several folders")
+	}
+	instance, err = cs.FindInstanceByFolderAndName(ctx, "gpu-folder", "dup")
+	if err != nil || instance.Id != "id4" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+
+	if err := cs.refreshInstanceCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	instances, err := cs.ListInstances(ctx)
+	if err != nil || len(instances) != 4 {
+		t.Errorf("expected instances of both folders to be cached, got %d, %v", len(instances), err)
+	}
+	instance, err = cs.FindInstanceByFolderAndName(ctx, "folder", "dup")
+	if err != nil || instance.Id != "id3" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+}
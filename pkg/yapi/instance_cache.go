@@ -0,0 +1,192 @@
+package yapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	cacheResultHit    = "hit"
+	cacheResultMiss   = "miss"
+	cacheResultBypass = "bypass"
+
+	// instanceCacheMaxAgeIntervals is the number of refresh intervals after which the instances are too stale to be served,
+	// so that lookups go to the API while the refreshes keep failing
+	instanceCacheMaxAgeIntervals = 3
+)
+
+type bypassCacheKey struct{}
+
+// WithCacheBypass returns a context that makes lookups go straight to the API instead of the caches.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// instanceCache keeps all instances of the instance folders, it is refreshed periodically by listing instances page by page.
+type instanceCache struct {
+	mu     sync.RWMutex
+	synced bool
+	// refreshedAt is the time of the last successful refresh, the instances are not served after maxAge
+	refreshedAt time.Time
+	maxAge      time.Duration
+	// now is replaced in tests
+	now func() time.Time
+
+	byID map[string]*compute.Instance
+	// instance names are unique within a folder only
+	byName map[string][]*compute.Instance
+}
+
+func newInstanceCache() *instanceCache {
+	return &instanceCache{
+		byID:   make(map[string]*compute.Instance),
+		byName: make(map[string][]*compute.Instance),
+		now:    time.Now,
+	}
+}
+
+// fresh tells whether the cache is synced and not stale, the lock must be held.
+func (c *instanceCache) fresh() bool {
+	return c.synced && (c.maxAge == 0 || c.now().Sub(c.refreshedAt) <= c.maxAge)
+}
+
+func (c *instanceCache) getByID(id string) (*compute.Instance, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	instance, ok := c.byID[id]
+	return instance, ok
+}
+
+// getByName returns the instance with the name from the folder, or from any folder if folderID is empty.
+// Ambiguous names are reported as missing, so that the lookup goes to the API.
+func (c *instanceCache) getByName(folderID, name string) (*compute.Instance, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var found *compute.Instance
+	for _, instance := range c.byName[name] {
+		if len(folderID) > 0 && instance.FolderId != folderID {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = instance
+	}
+
+	return found, found != nil
+}
+
+func (c *instanceCache) list() ([]*compute.Instance, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.fresh() {
+		return nil, false
+	}
+
+	ret := make([]*compute.Instance, 0, len(c.byID))
+	for _, instance := range c.byID {
+		ret = append(ret, instance)
+	}
+
+	return ret, true
+}
+
+func (c *instanceCache) isSynced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.fresh()
+}
+
+func (c *instanceCache) store(instance *compute.Instance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.byID[instance.Id] = instance
+
+	// the instance replaces the one with the same name from its folder
+	var sameName []*compute.Instance
+	for _, cached := range c.byName[instance.Name] {
+		if cached.FolderId != instance.FolderId {
+			sameName = append(sameName, cached)
+		}
+	}
+	c.byName[instance.Name] = append(sameName, instance)
+}
+
+func (c *instanceCache) replace(instances []*compute.Instance) {
+	byID := make(map[string]*compute.Instance, len(instances))
+	byName := make(map[string][]*compute.Instance, len(instances))
+	for _, instance := range instances {
+		byID[instance.Id] = instance
+		byName[instance.Name] = append(byName[instance.Name], instance)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.byID = byID
+	c.byName = byName
+	c.synced = true
+	c.refreshedAt = c.now()
+}
+
+// RunInstanceCache refreshes the instance cache every interval until stop is closed.
+// Lookups are served by the API directly until the first refresh succeeds
+// and whenever the refreshes have been failing for several intervals.
+func (cs *ComputeService) RunInstanceCache(stop <-chan struct{}, interval time.Duration) {
+	cs.instanceCache.mu.Lock()
+	cs.instanceCache.maxAge = instanceCacheMaxAgeIntervals * interval
+	cs.instanceCache.mu.Unlock()
+
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+
+		if err := cs.refreshInstanceCache(ctx); err != nil {
+			klog.Errorf("failed to refresh instance cache: %s", err)
+		}
+	}, interval, stop)
+}
+
+func (cs *ComputeService) refreshInstanceCache(ctx context.Context) error {
+	instances, err := cs.listAllInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	cs.instanceCache.replace(instances)
+	instanceCacheSize.Set(float64(len(instances)))
+
+	return nil
+}
+
+// cachedInstanceLookup returns the instance from the cache if possible and records the cache metrics.
+// A nil cache means that the lookup should go to the API.
+func (cs *ComputeService) cachedInstanceLookup(ctx context.Context, lookup func(*instanceCache) (*compute.Instance, bool)) *compute.Instance {
+	if cacheBypassed(ctx) || !cs.instanceCache.isSynced() {
+		instanceCacheRequests.WithLabelValues(cacheResultBypass).Inc()
+		return nil
+	}
+
+	if instance, ok := lookup(cs.instanceCache); ok {
+		instanceCacheRequests.WithLabelValues(cacheResultHit).Inc()
+		return instance
+	}
+
+	instanceCacheRequests.WithLabelValues(cacheResultMiss).Inc()
+	return nil
+}
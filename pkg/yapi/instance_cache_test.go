@@ -0,0 +1,186 @@
+package yapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInstanceService serves instances from memory two per page and counts API calls.
+type fakeInstanceService struct {
+	compute.InstanceServiceClient
+
+	instances []*compute.Instance
+	calls     int
+}
+
+func (f *fakeInstanceService) Get(_ context.Context, in *compute.GetInstanceRequest, _ ...grpc.CallOption) (*compute.Instance, error) {
+	f.calls++
+	for _, instance := range f.instances {
+		if instance.Id == in.InstanceId {
+			return instance, nil
+		}
+	}
+
+	return nil, status.Error(codes.NotFound, "instance not found")
+}
+
+func (f *fakeInstanceService) List(_ context.Context, in *compute.ListInstancesRequest, _ ...grpc.CallOption) (*compute.ListInstancesResponse, error) {
+	f.calls++
+
+	var matching []*compute.Instance
+	for _, instance := range f.instances {
+		if len(instance.FolderId) > 0 && instance.FolderId != in.FolderId {
+			continue
+		}
+		if in.Filter == "" || in.Filter == fmt.Sprintf("name = %q", instance.Name) {
+			matching = append(matching, instance)
+		}
+	}
+
+	start, _ := strconv.Atoi(in.PageToken)
+	end := start + 2
+	resp := &compute.ListInstancesResponse{}
+	if end < len(matching) {
+		resp.NextPageToken = strconv.Itoa(end)
+	} else {
+		end = len(matching)
+	}
+	resp.Instances = matching[start:end]
+
+	return resp, nil
+}
+
+func TestInstanceCache(t *testing.T) {
+	instanceSvc := &fakeInstanceService{}
+	for i := 0; i < 5; i++ {
+		instanceSvc.instances = append(instanceSvc.instances, &compute.Instance{Id: fmt.Sprintf("id%d", i), Name: fmt.Sprintf("name%d", i)})
+	}
+	cs := NewComputeService(instanceSvc, nil, &CloudContext{FolderID: "folder"})
+	ctx := context.Background()
+
+	// not synced yet, lookups go to the API
+	if _, err := cs.FindInstanceByName(ctx, "name1"); err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 1 {
+		t.Errorf("expected 1 API call before the cache is synced, got %d", instanceSvc.calls)
+	}
+
+	// a miss of a listing that already went to the API is not repeated
+	instanceSvc.calls = 0
+	match := func(instance *compute.Instance) bool { return instance.Name == "missing" }
+	if instance, err := cs.FindInstance(ctx, "the name \"missing\"", match); instance != nil || err != nil {
+		t.Errorf("expected no instance and no error, got %v, %v", instance, err)
+	}
+	if instanceSvc.calls != 3 {
+		t.Errorf("expected 3 pages to be listed once, got %d", instanceSvc.calls)
+	}
+
+	instanceSvc.calls = 0
+	if err := cs.refreshInstanceCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 3 {
+		t.Errorf("expected 3 pages to be listed, got %d", instanceSvc.calls)
+	}
+
+	instanceSvc.calls = 0
+	instances, err := cs.ListInstances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 5 {
+		t.Errorf("expected 5 cached instances, got %d", len(instances))
+	}
+	instance, err := cs.FindInstanceByName(ctx, "name3")
+	if err != nil || instance.Id != "id3" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+	instance, err = cs.GetInstanceByID(ctx, "id4")
+	if err != nil || instance.Name != "name4" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+	if instanceSvc.calls != 0 {
+		t.Errorf("expected lookups to be served from the cache, got %d API calls", instanceSvc.calls)
+	}
+
+	// misses and bypassed reads go to the API
+	instanceSvc.instances = append(instanceSvc.instances, &compute.Instance{Id: "id5", Name: "name5"})
+	instance, err = cs.GetInstanceByID(ctx, "id5")
+	if err != nil || instance.Name != "name5" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+	if _, err = cs.GetInstanceByID(WithCacheBypass(ctx), "id4"); err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 2 {
+		t.Errorf("expected 2 API calls, got %d", instanceSvc.calls)
+	}
+
+	if _, err = cs.GetInstanceByID(ctx, "missing"); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	if instance, err = cs.FindInstanceByName(ctx, "missing"); instance != nil || err != nil {
+		t.Errorf("expected no instance and no error, got %v, %v", instance, err)
+	}
+
+	// cache misses are retried against the API
+	instanceSvc.instances = append(instanceSvc.instances, &compute.Instance{Id: "id6", Name: "name6"})
+	instanceSvc.calls = 0
+	if instance, err = cs.FindInstance(ctx, "the name \"name6\"", func(instance *compute.Instance) bool { return instance.Name == "name6" }); err != nil || instance.Id != "id6" {
+		t.Errorf("unexpected lookup result %v, %v", instance, err)
+	}
+	if instanceSvc.calls != 4 {
+		t.Errorf("expected a single listing of 4 pages, got %d API calls", instanceSvc.calls)
+	}
+}
+
+func TestInstanceCacheStaleness(t *testing.T) {
+	instanceSvc := &fakeInstanceService{instances: []*compute.Instance{{Id: "id1", Name: "name1"}}}
+	cs := NewComputeService(instanceSvc, nil, &CloudContext{FolderID: "folder"})
+	ctx := context.Background()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cs.instanceCache.now = func() time.Time { return now }
+	cs.instanceCache.maxAge = instanceCacheMaxAgeIntervals * time.Minute
+
+	if err := cs.refreshInstanceCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	instanceSvc.calls = 0
+	now = now.Add(instanceCacheMaxAgeIntervals * time.Minute)
+	if _, err := cs.GetInstanceByID(ctx, "id1"); err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 0 {
+		t.Errorf("expected the lookup to be served from the cache, got %d API calls", instanceSvc.calls)
+	}
+
+	// the refreshes have been failing for too long
+	now = now.Add(time.Second)
+	if cs.instanceCache.isSynced() {
+		t.Error("stale cache should not be synced")
+	}
+	if _, err := cs.GetInstanceByID(ctx, "id1"); err != nil {
+		t.Fatal(err)
+	}
+	if instanceSvc.calls != 1 {
+		t.Errorf("expected the lookup of a stale cache to go to the API, got %d API calls", instanceSvc.calls)
+	}
+
+	if err := cs.refreshInstanceCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !cs.instanceCache.isSynced() {
+		t.Error("cache should be synced again after a refresh")
+	}
+}
@@ -0,0 +1,116 @@
+package yapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+)
+
+// instance group membership changes only when groups are scaled or created
+const defaultInstanceGroupMembershipTTL = 5 * time.Minute
+
+type InstanceGroupService struct {
+	cloudCtx         *CloudContext
+	InstanceGroupSvc instancegroup.InstanceGroupServiceClient
+
+	// folderIDs returns the folders searched for instance groups, defaults to the cluster's folder
+	folderIDs func(ctx context.Context) ([]string, error)
+
+	mu         sync.Mutex
+	ttl        time.Duration
+	membership map[string]string
+	expiresAt  time.Time
+}
+
+func NewInstanceGroupService(instanceGroupSvc instancegroup.InstanceGroupServiceClient, cloudCtx *CloudContext) *InstanceGroupService {
+	return &InstanceGroupService{
+		cloudCtx:         cloudCtx,
+		InstanceGroupSvc: instanceGroupSvc,
+		ttl:              defaultInstanceGroupMembershipTTL,
+	}
+}
+
+// GetInstanceGroupID returns the ID of the instance group managing the instance, or an empty string if there is none.
+// Membership of all the instance groups of the instance folders is listed at once and kept for a few minutes.
+func (igs *InstanceGroupService) GetInstanceGroupID(ctx context.Context, instanceID string) (string, error) {
+	igs.mu.Lock()
+	defer igs.mu.Unlock()
+
+	if igs.membership == nil || time.Now().After(igs.expiresAt) {
+		membership, err := igs.listMembership(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		igs.membership = membership
+		igs.expiresAt = time.Now().Add(igs.ttl)
+	}
+
+	return igs.membership[instanceID], nil
+}
+
+func (igs *InstanceGroupService) listMembership(ctx context.Context) (map[string]string, error) {
+	folderIDs := []string{igs.cloudCtx.FolderID}
+	if igs.folderIDs != nil {
+		var err error
+		folderIDs, err = igs.folderIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	membership := make(map[string]string)
+	for _, folderID := range folderIDs {
+		if err := igs.listFolderMembership(ctx, folderID, membership); err != nil {
+			return nil, err
+		}
+	}
+
+	return membership, nil
+}
+
+func (igs *InstanceGroupService) listFolderMembership(ctx context.Context, folderID string, membership map[string]string) error {
+	var groupsPageToken string
+	for {
+		groups, err := igs.InstanceGroupSvc.List(ctx, &instancegroup.ListInstanceGroupsRequest{
+			FolderId:  folderID,
+			PageSize:  1000,
+			PageToken: groupsPageToken,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, group := range groups.InstanceGroups {
+			var instancesPageToken string
+			for {
+				instances, err := igs.InstanceGroupSvc.ListInstances(ctx, &instancegroup.ListInstanceGroupInstancesRequest{
+					InstanceGroupId: group.Id,
+					PageSize:        1000,
+					PageToken:       instancesPageToken,
+				})
+				if err != nil {
+					return err
+				}
+
+				for _, instance := range instances.Instances {
+					if len(instance.InstanceId) > 0 {
+						membership[instance.InstanceId] = group.Id
+					}
+				}
+
+				instancesPageToken = instances.NextPageToken
+				if len(instancesPageToken) == 0 {
+					break
+				}
+			}
+		}
+
+		groupsPageToken = groups.NextPageToken
+		if len(groupsPageToken) == 0 {
+			return nil
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"strings"
 
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
@@ -11,6 +12,7 @@ import (
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -33,14 +35,9 @@ func NewLoadBalancerService(lbSvc loadbalancer.NetworkLoadBalancerServiceClient,
 	}
 }
 
-func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name string, listenerSpec []*loadbalancer.ListenerSpec, attachedTGs []*loadbalancer.AttachedTargetGroup) (string, error) {
-	var nlbType = loadbalancer.NetworkLoadBalancer_EXTERNAL
-	for _, listener := range listenerSpec {
-		if _, ok := listener.Address.(*loadbalancer.ListenerSpec_InternalAddressSpec); ok {
-			nlbType = loadbalancer.NetworkLoadBalancer_INTERNAL
-			break
-		}
-	}
+// CreateOrUpdateLB makes the NLB match the spec. Labels are only updated if labels is not nil.
+func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name string, labels map[string]string, listenerSpec []*loadbalancer.ListenerSpec, attachedTGs []*loadbalancer.AttachedTargetGroup) (*loadbalancer.NetworkLoadBalancer, error) {
+	nlbType := NetworkLoadBalancerType(listenerSpec)
 
 	log.Printf("Getting LB by name: %q", name)
 	lb, err := ySvc.GetLbByName(ctx, name)
@@ -48,13 +45,14 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 		if status.Code(err) == codes.NotFound {
 			log.Println("LB not found, creating new LB")
 		} else {
-			return "", err
+			return nil, err
 		}
 	}
 
 	lbCreateRequest := &loadbalancer.CreateNetworkLoadBalancerRequest{
 		FolderId:             ySvc.cloudCtx.FolderID,
 		Name:                 name,
+		Labels:               labels,
 		RegionId:             ySvc.cloudCtx.RegionID,
 		Type:                 nlbType,
 		ListenerSpecs:        listenerSpec,
@@ -68,10 +66,10 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 			return ySvc.LbSvc.Create(ctx, lbCreateRequest)
 		})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		return result.(*loadbalancer.NetworkLoadBalancer).Listeners[0].Address, nil
+		return result.(*loadbalancer.NetworkLoadBalancer), nil
 	}
 
 	if lb != nil && shouldRecreate(lb, lbCreateRequest) {
@@ -81,17 +79,17 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 			return ySvc.LbSvc.Delete(ctx, &loadbalancer.DeleteNetworkLoadBalancerRequest{NetworkLoadBalancerId: lb.Id})
 		})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
 			return ySvc.LbSvc.Create(ctx, lbCreateRequest)
 		})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		return result.(*loadbalancer.NetworkLoadBalancer).Listeners[0].Address, nil
+		return result.(*loadbalancer.NetworkLoadBalancer), nil
 	}
 
 	log.Printf("LB %q already exists, attempting an update\n", name)
@@ -112,7 +110,7 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 		})
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		dirty = true
@@ -130,27 +128,44 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 		})
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		dirty = true
 	}
 
-	tgsToAttach, tgsToDetach := diffAttachedTargetGroups(attachedTGs, lb.AttachedTargetGroups)
-	for _, tg := range tgsToDetach {
-		req := &loadbalancer.DetachNetworkLoadBalancerTargetGroupRequest{
+	if labels != nil && !maps.Equal(labels, lb.Labels) {
+		req := &loadbalancer.UpdateNetworkLoadBalancerRequest{
 			NetworkLoadBalancerId: lb.Id,
-			TargetGroupId:         tg.TargetGroupId,
+			UpdateMask:            &fieldmaskpb.FieldMask{Paths: []string{"labels"}},
+			Labels:                labels,
 		}
-		log.Printf("Detaching TargetGroup: %v", req.String())
+		log.Printf("Updating LoadBalancer labels: %s", req.String())
 
-		// todo(31337Ghost) it will be better to send requests concurrently
 		_, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
-			return ySvc.LbSvc.DetachTargetGroup(ctx, req)
+			return ySvc.LbSvc.Update(ctx, req)
 		})
 
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+
+		dirty = true
+	}
+
+	// a new target group is attached before the one it replaces is detached, so that the NLB keeps serving,
+	// while a target group changing only its health checks has to be detached first
+	tgsToAttach, tgsToDetach := diffAttachedTargetGroups(attachedTGs, lb.AttachedTargetGroups)
+	reattached := sets.New[string]()
+	for _, tg := range tgsToAttach {
+		reattached.Insert(tg.TargetGroupId)
+	}
+	for _, tg := range tgsToDetach {
+		if !reattached.Has(tg.TargetGroupId) {
+			continue
+		}
+		if err := ySvc.detachTG(ctx, lb.Id, tg.TargetGroupId); err != nil {
+			return nil, err
 		}
 
 		dirty = true
@@ -168,7 +183,17 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 		})
 
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+
+		dirty = true
+	}
+	for _, tg := range tgsToDetach {
+		if reattached.Has(tg.TargetGroupId) {
+			continue
+		}
+		if err := ySvc.detachTG(ctx, lb.Id, tg.TargetGroupId); err != nil {
+			return nil, err
 		}
 
 		dirty = true
@@ -179,11 +204,29 @@ func (ySvc *LoadBalancerService) CreateOrUpdateLB(ctx context.Context, name stri
 		log.Printf("Retrieving LoadBalancer %q after update", name)
 		lb, err = ySvc.GetLbByName(ctx, name)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if lb == nil {
+			return nil, fmt.Errorf("LoadBalancer %q disappeared after update", name)
 		}
 	}
 
-	return lb.Listeners[0].Address, nil
+	return lb, nil
+}
+
+func (ySvc *LoadBalancerService) detachTG(ctx context.Context, lbID, tgID string) error {
+	req := &loadbalancer.DetachNetworkLoadBalancerTargetGroupRequest{
+		NetworkLoadBalancerId: lbID,
+		TargetGroupId:         tgID,
+	}
+	log.Printf("Detaching TargetGroup: %v", req.String())
+
+	// todo(31337Ghost) it will be better to send requests concurrently
+	_, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.LbSvc.DetachTargetGroup(ctx, req)
+	})
+
+	return err
 }
 
 func (ySvc *LoadBalancerService) GetTGsByClusterName(ctx context.Context, clusterName string) (ret []*loadbalancer.TargetGroup, err error) {
@@ -205,6 +248,90 @@ func (ySvc *LoadBalancerService) GetTGsByClusterName(ctx context.Context, cluste
 	return
 }
 
+// RemoveAddressesFromTGs removes the targets with the given addresses from all target groups of the cluster.
+func (ySvc *LoadBalancerService) RemoveAddressesFromTGs(ctx context.Context, clusterName string, addresses []string) error {
+	tgs, err := ySvc.GetTGsByClusterName(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+
+	addressSet := sets.New[string](addresses...)
+	for _, tg := range tgs {
+		var targetsToRemove []*loadbalancer.Target
+		for _, target := range tg.Targets {
+			if addressSet.Has(target.Address) {
+				targetsToRemove = append(targetsToRemove, target)
+			}
+		}
+		if len(targetsToRemove) == 0 {
+			continue
+		}
+
+		req := &loadbalancer.RemoveTargetsRequest{
+			TargetGroupId: tg.Id,
+			Targets:       targetsToRemove,
+		}
+		log.Printf("Removing Targets: %s", req.String())
+
+		_, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+			return ySvc.TgSvc.RemoveTargets(ctx, req)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove targets from TargetGroup %q: %s", tg.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// NetworkLoadBalancerType returns the type of the NLB with the listeners, NLBs with internal listeners are INTERNAL.
+func NetworkLoadBalancerType(listenerSpecs []*loadbalancer.ListenerSpec) loadbalancer.NetworkLoadBalancer_Type {
+	for _, listener := range listenerSpecs {
+		if _, ok := listener.Address.(*loadbalancer.ListenerSpec_InternalAddressSpec); ok {
+			return loadbalancer.NetworkLoadBalancer_INTERNAL
+		}
+	}
+
+	return loadbalancer.NetworkLoadBalancer_EXTERNAL
+}
+
+// HasHealthyTargets tells whether at least one target of the target group passes the health checks of the NLB.
+func (ySvc *LoadBalancerService) HasHealthyTargets(ctx context.Context, lbID, tgID string) (bool, error) {
+	result, err := ySvc.LbSvc.GetTargetStates(ctx, &loadbalancer.GetTargetStatesRequest{
+		NetworkLoadBalancerId: lbID,
+		TargetGroupId:         tgID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, state := range result.TargetStates {
+		if state.Status == loadbalancer.TargetState_HEALTHY {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (ySvc *LoadBalancerService) RenameLB(ctx context.Context, lb *loadbalancer.NetworkLoadBalancer, name string) (*loadbalancer.NetworkLoadBalancer, error) {
+	req := &loadbalancer.UpdateNetworkLoadBalancerRequest{
+		NetworkLoadBalancerId: lb.Id,
+		UpdateMask:            &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+		Name:                  name,
+	}
+	log.Printf("Renaming LoadBalancer: %s", req.String())
+
+	result, _, err := ySvc.cloudCtx.OperationWaiter(ctx, func() (*operation.Operation, error) {
+		return ySvc.LbSvc.Update(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*loadbalancer.NetworkLoadBalancer), nil
+}
+
 func (ySvc *LoadBalancerService) RemoveLBByName(ctx context.Context, name string) error {
 	log.Printf("Retrieving LB by name %q", name)
 	lb, err := ySvc.GetLbByName(ctx, name)
@@ -356,6 +483,10 @@ func (ySvc *LoadBalancerService) GetLbByName(ctx context.Context, name string) (
 	return result.NetworkLoadBalancers[0], nil
 }
 
+func (ySvc *LoadBalancerService) GetTgByID(ctx context.Context, tgID string) (*loadbalancer.TargetGroup, error) {
+	return ySvc.TgSvc.Get(ctx, &loadbalancer.GetTargetGroupRequest{TargetGroupId: tgID})
+}
+
 func (ySvc *LoadBalancerService) GetTgByName(ctx context.Context, name string) (*loadbalancer.TargetGroup, error) {
 	result, err := ySvc.TgSvc.List(ctx, &loadbalancer.ListTargetGroupsRequest{
 		FolderId: ySvc.cloudCtx.FolderID,
@@ -410,6 +541,12 @@ func diffTargetGroupTargets(expectedTargets []*loadbalancer.Target, actualTarget
 	return targetsToAdd, targetsToRemove
 }
 
+// ListenersToRemove returns the listeners of the NLB that CreateOrUpdateLB removes or re-creates to match the specs.
+func ListenersToRemove(lb *loadbalancer.NetworkLoadBalancer, listenerSpecs []*loadbalancer.ListenerSpec) []*loadbalancer.Listener {
+	_, listenersToRemove := diffListeners(listenerSpecs, lb.GetListeners())
+	return listenersToRemove
+}
+
 func diffListeners(expectedListeners []*loadbalancer.ListenerSpec, actualListeners []*loadbalancer.Listener) (listenersToAdd []*loadbalancer.ListenerSpec, listenersToRemove []*loadbalancer.Listener) {
 	foundSet := make(map[string]bool)
 
@@ -448,9 +585,27 @@ func nlbListenersAreEqual(actual *loadbalancer.Listener, expected *loadbalancer.
 	if actual.TargetPort != expected.TargetPort {
 		return false
 	}
+	if listenerIPVersion(actual.IpVersion) != listenerIPVersion(listenerSpecIPVersion(expected)) {
+		return false
+	}
 	return true
 }
 
+func listenerSpecIPVersion(spec *loadbalancer.ListenerSpec) loadbalancer.IpVersion {
+	if internal := spec.GetInternalAddressSpec(); internal != nil {
+		return internal.IpVersion
+	}
+	return spec.GetExternalAddressSpec().GetIpVersion()
+}
+
+// listenerIPVersion treats listeners without a version as IPv4, which is the API default.
+func listenerIPVersion(version loadbalancer.IpVersion) loadbalancer.IpVersion {
+	if version == loadbalancer.IpVersion_IP_VERSION_UNSPECIFIED {
+		return loadbalancer.IpVersion_IPV4
+	}
+	return version
+}
+
 func diffAttachedTargetGroups(expectedTGs []*loadbalancer.AttachedTargetGroup, actualTGs []*loadbalancer.AttachedTargetGroup) (tgsToAttach []*loadbalancer.AttachedTargetGroup, tgsToDetach []*loadbalancer.AttachedTargetGroup) {
 	foundSet := make(map[string]bool)
 
@@ -495,16 +650,27 @@ func nlbAttachedTargetGroupsAreEqual(actual *loadbalancer.AttachedTargetGroup, e
 	if actualHealthCheck.HealthyThreshold != expectedHealthCheck.HealthyThreshold {
 		return false
 	}
-	actualHealthCheckHttpOptions := actualHealthCheck.GetHttpOptions()
-	if actualHealthCheckHttpOptions == nil {
-		return false
-	}
-	expectedHealthCheckHttpOptions := expectedHealthCheck.GetHttpOptions()
-	if actualHealthCheckHttpOptions.Port != expectedHealthCheckHttpOptions.Port {
-		return false
-	}
-	if actualHealthCheckHttpOptions.Path != expectedHealthCheckHttpOptions.Path {
-		return false
+	// the options are a oneof, so switching between HTTP and TCP checks must be detected as well
+	if expectedHealthCheckTcpOptions := expectedHealthCheck.GetTcpOptions(); expectedHealthCheckTcpOptions != nil {
+		actualHealthCheckTcpOptions := actualHealthCheck.GetTcpOptions()
+		if actualHealthCheckTcpOptions == nil {
+			return false
+		}
+		if actualHealthCheckTcpOptions.Port != expectedHealthCheckTcpOptions.Port {
+			return false
+		}
+	} else {
+		actualHealthCheckHttpOptions := actualHealthCheck.GetHttpOptions()
+		if actualHealthCheckHttpOptions == nil {
+			return false
+		}
+		expectedHealthCheckHttpOptions := expectedHealthCheck.GetHttpOptions()
+		if actualHealthCheckHttpOptions.Port != expectedHealthCheckHttpOptions.Port {
+			return false
+		}
+		if actualHealthCheckHttpOptions.Path != expectedHealthCheckHttpOptions.Path {
+			return false
+		}
 	}
 
 	if actualHealthCheck.Interval.GetSeconds() != expectedHealthCheck.Interval.GetSeconds() {
@@ -0,0 +1,74 @@
+package yapi
+
+import (
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/loadbalancer/v1"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestNlbAttachedTargetGroupsAreEqual(t *testing.T) {
+	newTG := func(options interface{}) *loadbalancer.AttachedTargetGroup {
+		healthCheck := &loadbalancer.HealthCheck{
+			Name:               "kube-health-check",
+			Interval:           &durationpb.Duration{Seconds: 2},
+			Timeout:            &durationpb.Duration{Seconds: 1},
+			UnhealthyThreshold: 2,
+			HealthyThreshold:   2,
+		}
+		switch options := options.(type) {
+		case *loadbalancer.HealthCheck_TcpOptions:
+			healthCheck.Options = &loadbalancer.HealthCheck_TcpOptions_{TcpOptions: options}
+		case *loadbalancer.HealthCheck_HttpOptions:
+			healthCheck.Options = &loadbalancer.HealthCheck_HttpOptions_{HttpOptions: options}
+		}
+
+		return &loadbalancer.AttachedTargetGroup{TargetGroupId: "tg", HealthChecks: []*loadbalancer.HealthCheck{healthCheck}}
+	}
+
+	http := newTG(&loadbalancer.HealthCheck_HttpOptions{Port: 10256, Path: "/healthz"})
+	tcp := newTG(&loadbalancer.HealthCheck_TcpOptions{Port: 30080})
+
+	tests := []struct {
+		name             string
+		actual, expected *loadbalancer.AttachedTargetGroup
+		equal            bool
+	}{
+		{name: "same HTTP", actual: http, expected: newTG(&loadbalancer.HealthCheck_HttpOptions{Port: 10256, Path: "/healthz"}), equal: true},
+		{name: "same TCP", actual: tcp, expected: newTG(&loadbalancer.HealthCheck_TcpOptions{Port: 30080}), equal: true},
+		{name: "TCP port changed", actual: tcp, expected: newTG(&loadbalancer.HealthCheck_TcpOptions{Port: 30081})},
+		{name: "HTTP to TCP", actual: http, expected: tcp},
+		{name: "TCP to HTTP", actual: tcp, expected: http},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := nlbAttachedTargetGroupsAreEqual(tt.actual, tt.expected); equal != tt.equal {
+				t.Errorf("expected %t, got %t", tt.equal, equal)
+			}
+		})
+	}
+}
+
+func TestDiffListenersIPVersion(t *testing.T) {
+	actual := []*loadbalancer.Listener{
+		{Name: "http", Port: 80, TargetPort: 30080, Protocol: loadbalancer.Listener_TCP, IpVersion: loadbalancer.IpVersion_IPV4},
+	}
+	newSpec := func(name string, ipVersion loadbalancer.IpVersion) *loadbalancer.ListenerSpec {
+		return &loadbalancer.ListenerSpec{
+			Name: name, Port: 80, TargetPort: 30080, Protocol: loadbalancer.Listener_TCP,
+			Address: &loadbalancer.ListenerSpec_ExternalAddressSpec{ExternalAddressSpec: &loadbalancer.ExternalAddressSpec{IpVersion: ipVersion}},
+		}
+	}
+
+	toAdd, toRemove := diffListeners([]*loadbalancer.ListenerSpec{
+		newSpec("http", loadbalancer.IpVersion_IP_VERSION_UNSPECIFIED),
+		newSpec("http-ipv6", loadbalancer.IpVersion_IPV6),
+	}, actual)
+	if len(toRemove) != 0 {
+		t.Errorf("unversioned IPv4 listener should be kept, removing %v", toRemove)
+	}
+	if len(toAdd) != 1 || toAdd[0].Name != "http-ipv6" {
+		t.Errorf("only the IPv6 listener should be added, adding %v", toAdd)
+	}
+}
@@ -0,0 +1,50 @@
+package yapi
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const metricsSubsystem = "yandex_ccm"
+
+var (
+	instanceCacheRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "instance_cache_requests_total",
+			Help:           "Number of instance lookups served by the instance cache, partitioned by result (hit, miss or bypass).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+	subnetCacheRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "subnet_cache_requests_total",
+			Help:           "Number of subnet lookups served by the subnet cache, partitioned by result (hit, miss or bypass).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+	instanceCacheSize = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "instance_cache_size",
+			Help:           "Number of instances in the instance cache after the last refresh.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+var registerMetricsOnce sync.Once
+
+// RegisterMetrics registers the API client metrics in the legacy registry served by the CCM on /metrics.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(instanceCacheRequests)
+		legacyregistry.MustRegister(instanceCacheSize)
+		legacyregistry.MustRegister(subnetCacheRequests)
+	})
+}
@@ -0,0 +1,133 @@
+package yapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// subnets rarely change, so they are kept for a long time
+const defaultSubnetCacheTTL = 10 * time.Minute
+
+// Subnet is the subnet metadata kept by the VPCService cache.
+type Subnet struct {
+	ID           string
+	NetworkID    string
+	ZoneID       string
+	V4CIDRBlocks []string
+	V6CIDRBlocks []string
+	Labels       map[string]string
+}
+
+type subnetCacheEntry struct {
+	subnet    *Subnet
+	expiresAt time.Time
+}
+
+type subnetCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]subnetCacheEntry
+}
+
+func newSubnetCache(ttl time.Duration) *subnetCache {
+	return &subnetCache{
+		ttl:     ttl,
+		entries: make(map[string]subnetCacheEntry),
+	}
+}
+
+func (c *subnetCache) get(subnetID string) (*Subnet, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[subnetID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.subnet, true
+}
+
+func (c *subnetCache) store(subnet *Subnet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[subnet.ID] = subnetCacheEntry{subnet: subnet, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *subnetCache) invalidate(subnetID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, subnetID)
+}
+
+// GetSubnet returns subnet metadata, from the cache if possible. API errors, including NotFound, are returned as is.
+func (vs *VPCService) GetSubnet(ctx context.Context, subnetID string) (*Subnet, error) {
+	if cacheBypassed(ctx) {
+		subnetCacheRequests.WithLabelValues(cacheResultBypass).Inc()
+	} else if subnet, ok := vs.subnetCache.get(subnetID); ok {
+		subnetCacheRequests.WithLabelValues(cacheResultHit).Inc()
+		return subnet, nil
+	} else {
+		subnetCacheRequests.WithLabelValues(cacheResultMiss).Inc()
+	}
+
+	result, err := vs.SubnetSvc.Get(ctx, &vpc.GetSubnetRequest{SubnetId: subnetID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			vs.subnetCache.invalidate(subnetID)
+		}
+		return nil, err
+	}
+
+	subnet := subnetFromAPI(result)
+	vs.subnetCache.store(subnet)
+
+	return subnet, nil
+}
+
+// ListNetworkSubnets returns all subnets of the network and refreshes them in the cache.
+func (vs *VPCService) ListNetworkSubnets(ctx context.Context, networkID string) ([]*Subnet, error) {
+	var subnets []*Subnet
+
+	req := &vpc.ListNetworkSubnetsRequest{NetworkId: networkID, PageSize: 1000}
+	for {
+		result, err := vs.NetworkSvc.ListSubnets(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, apiSubnet := range result.Subnets {
+			subnet := subnetFromAPI(apiSubnet)
+			vs.subnetCache.store(subnet)
+			subnets = append(subnets, subnet)
+		}
+
+		if result.NextPageToken == "" {
+			return subnets, nil
+		}
+		req.PageToken = result.NextPageToken
+	}
+}
+
+func subnetFromAPI(subnet *vpc.Subnet) *Subnet {
+	return &Subnet{
+		ID:           subnet.Id,
+		NetworkID:    subnet.NetworkId,
+		ZoneID:       subnet.ZoneId,
+		V4CIDRBlocks: subnet.V4CidrBlocks,
+		V6CIDRBlocks: subnet.V6CidrBlocks,
+		Labels:       subnet.Labels,
+	}
+}
+
+// InvalidateSubnet drops the subnet from the cache, e.g. after it was found to be stale.
+func (vs *VPCService) InvalidateSubnet(subnetID string) {
+	vs.subnetCache.invalidate(subnetID)
+}
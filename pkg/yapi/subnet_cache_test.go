@@ -0,0 +1,72 @@
+package yapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSubnetService struct {
+	vpc.SubnetServiceClient
+
+	subnets map[string]*vpc.Subnet
+	calls   int
+}
+
+func (f *fakeSubnetService) Get(_ context.Context, in *vpc.GetSubnetRequest, _ ...grpc.CallOption) (*vpc.Subnet, error) {
+	f.calls++
+	if subnet, ok := f.subnets[in.SubnetId]; ok {
+		return subnet, nil
+	}
+
+	return nil, status.Error(codes.NotFound, "subnet not found")
+}
+
+func TestSubnetCache(t *testing.T) {
+	subnetSvc := &fakeSubnetService{subnets: map[string]*vpc.Subnet{
+		"subnet": {Id: "subnet", NetworkId: "network", ZoneId: "ru-central1-a", V4CidrBlocks: []string{"10.0.0.0/24"}},
+	}}
+	vs := NewVPCService(nil, subnetSvc, nil, nil, &CloudContext{})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		subnet, err := vs.GetSubnet(ctx, "subnet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if subnet.NetworkID != "network" || subnet.ZoneID != "ru-central1-a" || len(subnet.V4CIDRBlocks) != 1 {
+			t.Errorf("unexpected subnet %+v", subnet)
+		}
+	}
+	if subnetSvc.calls != 1 {
+		t.Errorf("expected a single API call, got %d", subnetSvc.calls)
+	}
+
+	// expired entries are fetched again
+	vs.subnetCache.ttl = -time.Second
+	vs.InvalidateSubnet("subnet")
+	if _, err := vs.GetSubnet(ctx, "subnet"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vs.GetSubnet(ctx, "subnet"); err != nil {
+		t.Fatal(err)
+	}
+	if subnetSvc.calls != 3 {
+		t.Errorf("expected expired entries to be fetched again, got %d API calls", subnetSvc.calls)
+	}
+
+	// deleted subnets are dropped from the cache
+	vs.subnetCache.ttl = time.Minute
+	delete(subnetSvc.subnets, "subnet")
+	if _, err := vs.GetSubnet(WithCacheBypass(ctx), "subnet"); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	if _, ok := vs.subnetCache.get("subnet"); ok {
+		t.Error("subnet should be invalidated on NotFound")
+	}
+}
@@ -10,16 +10,22 @@ type VPCService struct {
 	NetworkSvc    vpc.NetworkServiceClient
 	SubnetSvc     vpc.SubnetServiceClient
 	RouteTableSvc vpc.RouteTableServiceClient
+	AddressSvc    vpc.AddressServiceClient
+
+	subnetCache *subnetCache
 }
 
 func NewVPCService(nSvc vpc.NetworkServiceClient, sSvc vpc.SubnetServiceClient, rtSvc vpc.RouteTableServiceClient,
-	cloudCtx *CloudContext) *VPCService {
+	aSvc vpc.AddressServiceClient, cloudCtx *CloudContext) *VPCService {
 
 	return &VPCService{
 		NetworkSvc:    nSvc,
 		SubnetSvc:     sSvc,
 		RouteTableSvc: rtSvc,
+		AddressSvc:    aSvc,
 
 		cloudCtx: cloudCtx,
+
+		subnetCache: newSubnetCache(defaultSubnetCacheTTL),
 	}
 }